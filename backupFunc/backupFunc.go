@@ -1,105 +1,380 @@
 package backupFunc
 
 import (
+	"backup/compressFunc"
+	"backup/encryptFunc"
+	"backup/manifestFunc"
 	"backup/model"
+	"backup/namingFunc"
+	"backup/platformFunc"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 )
 
-// PerformDatabaseBackups runs all database backups concurrently
-func PerformDatabaseBackups(creds *model.DatabaseCredentials, addPathVersion string) error {
-	var wg sync.WaitGroup
-	wgCount := 2
-	errChan := make(chan error, wgCount)
+// PerformDatabaseBackups runs one backup per job on a bounded worker pool and
+// adds every artifact produced to the run manifest. Cancelling ctx kills the
+// running pg_dump processes. It returns one result per job, in job order,
+// and all failures joined into one error.
+func PerformDatabaseBackups(ctx context.Context, creds *model.DatabaseCredentials, binDir string, jobs []model.BackupJob, manifest *model.Manifest, config *model.BackupConfig) ([]model.SchemaResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	wg.Add(wgCount)
+	var mu sync.Mutex
+	results := make([]model.SchemaResult, len(jobs))
+	errs := make([]error, len(jobs))
 
-	go func() {
-		defer wg.Done()
-		if err := backupDatabasePublic(creds, addPathVersion); err != nil {
-			errChan <- fmt.Errorf("error backing up public database: %v", err)
+	hostOf := func(job model.BackupJob) string {
+		return creds.PgHost + ":" + creds.PgPort
+	}
+
+	progress := StartProgress()
+	defer progress.Stop()
+
+	pool := newWorkerPool(config.MaxParallel, config.MaxParallelPerHost)
+	pool.run(jobs, hostOf, func(i int) {
+		job := jobs[i]
+		result := model.SchemaResult{
+			Kind:     ArtifactKind(job),
+			Database: job.Database,
+			Schema:   job.Schema,
+			Status:   model.StatusSucceeded,
 		}
-	}()
 
-	go func() {
-		defer wg.Done()
-		if err := backupDatabaseNewSchema(creds, addPathVersion); err != nil {
-			errChan <- fmt.Errorf("error backing up private database: %v", err)
+		// Jobs still queued when the run is cancelled are not started
+		if ctx.Err() != nil {
+			result.Status = model.StatusCancelled
+			result.Error = ctx.Err().Error()
+			results[i] = result
+			errs[i] = fmt.Errorf("error backing up %s: %v", JobName(job), ctx.Err())
+			return
 		}
-	}()
 
-	// Wait for all goroutines to complete
-	wg.Wait()
-	close(errChan)
+		startedAt := time.Now()
+		artifact, err := BackupDatabase(ctx, creds, binDir, job, manifest, progress)
+		result.DurationSeconds = time.Since(startedAt).Seconds()
+
+		if err != nil {
+			result.Status = model.StatusFailed
+			if errors.Is(err, context.Canceled) {
+				result.Status = model.StatusCancelled
+			}
+			result.Error = err.Error()
+			var dumpErr *DumpError
+			if errors.As(err, &dumpErr) {
+				result.Error = dumpErr.Err.Error()
+				result.StderrExcerpt = dumpErr.Stderr
+			}
+			results[i] = result
+			errs[i] = fmt.Errorf("error backing up %s: %v", JobName(job), err)
+			log.Printf("Schema %s %s", JobName(job), result.Status)
+
+			if config.FailurePolicy == model.FailureCancel && result.Status == model.StatusFailed {
+				cancel()
+			}
+			return
+		}
+
+		result.ArtifactPath = artifact.Path
+		result.Bytes = artifact.Size
+		results[i] = result
+		log.Printf("Schema %s finished in %.1fs", JobName(job), result.DurationSeconds)
+
+		mu.Lock()
+		manifest.Artifacts = append(manifest.Artifacts, *artifact)
+		mu.Unlock()
+	})
+
+	return results, errors.Join(errs...)
+}
+
+// BackupDatabase dumps one schema with the format and options of the job.
+// The artifact is named after the run's start time. It is written under a
+// partial name and only renamed once pg_dump has finished successfully, so
+// an interrupted dump never looks like a complete one.
+func BackupDatabase(ctx context.Context, creds *model.DatabaseCredentials, binDir string, job model.BackupJob, manifest *model.Manifest, progress *Progress) (*model.ManifestArtifact, error) {
+	startedAt := time.Now()
 
-	// Check for errors
-	for err := range errChan {
-		return err
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
 	}
 
-	return nil
+	// Name the artifact, directory format produces a directory with this name
+	kind := ArtifactKind(job)
+	backupFile, err := artifactFile(creds, job, kind, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		fmt.Sprintf("--username=%s", creds.PgUser),
+		fmt.Sprintf("--host=%s", creds.PgHost),
+		fmt.Sprintf("--port=%s", creds.PgPort),
+		fmt.Sprintf("--dbname=%s", job.Database),
+		fmt.Sprintf("--schema=%s", job.Schema),
+		fmt.Sprintf("--format=%s", job.Format),
+		"--verbose",
+	}
+	if job.Format == model.FormatDirectory && job.Jobs > 1 {
+		args = append(args, fmt.Sprintf("--jobs=%d", job.Jobs))
+	}
+	if job.Snapshot != "" {
+		args = append(args, "--snapshot="+job.Snapshot)
+	}
+	args = append(args, contentArgs(job)...)
+	args = append(args, tableArgs(job)...)
+
+	// Custom format would compress again inside the archive
+	if job.Format == model.FormatCustom && job.Compression != model.CompressionNone {
+		args = append(args, "--compress=0")
+	}
+
+	// Targets can name their own tools directory
+	if job.PgBinDir != "" {
+		binDir = job.PgBinDir
+	}
+
+	args, err = runDumpTool(ctx, creds, progress, JobName(job), platformFunc.ToolPath(binDir, "pg_dump"), args, backupFile, job)
+	if err != nil {
+		return nil, err
+	}
+
+	size, checksum, err := manifestFunc.HashPath(backupFile)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing backup: %v", err)
+	}
+
+	return &model.ManifestArtifact{
+		Kind:            kind,
+		Database:        job.Database,
+		Schema:          job.Schema,
+		Path:            backupFile,
+		Size:            size,
+		SHA256:          checksum,
+		StartedAt:       startedAt,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+		Job:             job,
+		Args:            args,
+	}, nil
 }
 
-func BackupDatabase(creds *model.DatabaseCredentials, version, schema string) error {
-	programFilesDir := "C:\\Program Files\\PostgreSQL\\" + version + "\\bin"
+// JobName identifies a job in logs and errors as <database>.<schema>, with
+// the section appended for split-sections jobs
+func JobName(job model.BackupJob) string {
+	if job.Section != "" {
+		return fmt.Sprintf("%s.%s (%s)", job.Database, job.Schema, job.Section)
+	}
+	return job.Database + "." + job.Schema
+}
 
-	backupDir, _ := createSchemaDir(schema)
+// ArtifactKind names what a job's artifact holds, so each content mode and
+// section gets its own artifact name
+func ArtifactKind(job model.BackupJob) string {
+	switch {
+	case job.Section == model.SectionPreData:
+		return model.KindPreData
+	case job.Section == model.SectionData:
+		return model.KindDataSection
+	case job.Section == model.SectionPostData:
+		return model.KindPostData
+	case job.Content == model.ContentSchemaOnly:
+		return model.KindSchema
+	case job.Content == model.ContentDataOnly:
+		return model.KindData
+	default:
+		return model.KindDump
+	}
+}
 
-	// Create backup directory if not exists
-	if err := os.MkdirAll(backupDir, os.ModePerm); err != nil {
-		return fmt.Errorf("error creating backup directory: %v", err)
+// contentArgs selects the part of the schema pg_dump writes
+func contentArgs(job model.BackupJob) []string {
+	switch {
+	case job.Section != "":
+		return []string{"--section=" + job.Section}
+	case job.Content == model.ContentSchemaOnly:
+		return []string{"--schema-only"}
+	case job.Content == model.ContentDataOnly:
+		return []string{"--data-only"}
+	default:
+		return nil
 	}
+}
 
-	// Replace dots with underscores in PG_HOST
-	hostWithUnderscores := strings.ReplaceAll(creds.PgHost, ".", "_")
+// tableArgs turns the tables the job's rules resolved to into pg_dump options.
+// Names are quoted so pg_dump neither folds their case nor treats them as
+// patterns, and dumps exactly the tables listed by the dry run.
+func tableArgs(job model.BackupJob) []string {
+	var args []string
+	if len(job.TableRules.Include) > 0 {
+		for _, table := range job.Tables {
+			args = append(args, "--table="+qualifiedTable(job.Schema, table))
+		}
+	}
+	for _, table := range job.ExcludedTables {
+		args = append(args, "--exclude-table="+qualifiedTable(job.Schema, table))
+	}
+	for _, table := range job.TablesWithoutData {
+		args = append(args, "--exclude-table-data="+qualifiedTable(job.Schema, table))
+	}
+	return args
+}
 
-	// Combine PG_DATABASE and modified PG_HOST to create dataSource
-	dataSource := fmt.Sprintf("%s_%s", creds.PgDatabase, hostWithUnderscores)
+func qualifiedTable(schema, table string) string {
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(table)
+}
 
-	// Define timestamp
-	timestamp := time.Now().Format("2006_01_02_15_04_05")
+// artifactFile renders the job's naming template below its output root,
+// adds the format, compression and encryption extensions and creates the
+// directory the artifact goes into
+func artifactFile(creds *model.DatabaseCredentials, job model.BackupJob, kind string, manifest *model.Manifest) (string, error) {
+	template, err := namingFunc.Parse(job.NameTemplate)
+	if err != nil {
+		return "", err
+	}
 
-	// Create backup file name
-	backupFile := fmt.Sprintf("%s/%s-%s-dump.sql", backupDir, dataSource, timestamp)
+	name := template.Render(namingFunc.Values{
+		Database: job.Database,
+		Host:     creds.PgHost,
+		Port:     creds.PgPort,
+		Schema:   job.Schema,
+		Format:   job.Format,
+		RunID:    manifest.RunID,
+		Kind:     kind,
+		Time:     manifest.StartedAt,
+	})
 
-	// Create command
-	command := exec.Command(
-		filepath.Join(programFilesDir, "pg_dump.exe"),
-		fmt.Sprintf("--username=%s", creds.PgUser),
-		fmt.Sprintf("--host=%s", creds.PgHost),
-		fmt.Sprintf("--port=%s", creds.PgPort),
-		fmt.Sprintf("--dbname=%s", creds.PgDatabase),
-		fmt.Sprintf("--schema=%s", schema),
-		"--format=plain",
-		"--file", backupFile,
-	)
+	backupFile := filepath.Join(job.OutputRoot, filepath.FromSlash(name)) +
+		FormatExtension(job.Format) + compressFunc.Extension(job.Compression)
+	if encryptFunc.Enabled(job.Encryption) {
+		backupFile += encryptFunc.Extension
+	}
+
+	// Create backup directory if not exists
+	if err = os.MkdirAll(filepath.Dir(backupFile), os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating backup directory: %v", err)
+	}
+	return backupFile, nil
+}
+
+// runDumpTool runs pg_dump or pg_dumpall with args and moves its output to
+// backupFile. Its verbose output is logged under name and the bytes it
+// writes are reported to progress, which may be nil. The output is written
+// under a partial name first and renamed only after the tool exits
+// successfully. It returns the arguments used, naming backupFile.
+func runDumpTool(ctx context.Context, creds *model.DatabaseCredentials, progress *Progress, name, toolPath string, args []string, backupFile string, job model.BackupJob) ([]string, error) {
+	partialFile := backupFile + PartialExtension
+
+	// Compressed or encrypted dumps are streamed from stdout instead of written by the tool
+	streamed := job.Compression != model.CompressionNone || encryptFunc.Enabled(job.Encryption)
+
+	// The tool writes the partial file, the manifest records the final one
+	recorded := args
+	if !streamed {
+		recorded = append(slices.Clip(args), "--file", backupFile)
+		args = append(slices.Clip(args), "--file", partialFile)
+	}
+
+	// Create command, it is killed when the run is cancelled or the job times out
+	command := exec.CommandContext(ctx, toolPath, args...)
+	command.WaitDelay = processWaitDelay
 
 	// Add password
 	command.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", creds.PgPassword))
 
+	// Log verbose output as progress and keep the end of stderr so failures explain themselves
+	stderr := newLineTail(stderrLines, newProgressLog(name).line)
+	command.Stderr = stderr
+
+	// Streamed output is counted as it is written, otherwise the artifact is polled
+	transfer := progress.track(name, "", job.EstimatedBytes)
+	if !streamed {
+		transfer.path = partialFile
+	}
+	defer progress.untrack(transfer)
+
 	// Execute command
-	if err := command.Run(); err != nil {
-		return fmt.Errorf("error during backup: %v", err)
+	var err error
+	if streamed {
+		err = runStreamedDump(command, partialFile, job, transfer)
+	} else if err = command.Run(); err != nil {
+		err = fmt.Errorf("error during backup: %v", err)
+	}
+	if err != nil {
+		os.RemoveAll(partialFile)
+		if ctx.Err() != nil {
+			err = fmt.Errorf("backup interrupted: %w", ctx.Err())
+		}
+		return nil, &DumpError{Err: err, Stderr: stderr.String()}
 	}
 
-	return nil
-}
+	if err = os.Rename(partialFile, backupFile); err != nil {
+		os.RemoveAll(partialFile)
+		return nil, fmt.Errorf("error moving backup into place: %v", err)
+	}
+	if err = syncDir(filepath.Dir(backupFile)); err != nil {
+		return nil, fmt.Errorf("error flushing backup directory: %v", err)
+	}
 
-func createSchemaDir(schema string) (string, error) {
-	schemaDir := fmt.Sprintf("./backups/%s", schema)
-	return schemaDir, nil
+	return recorded, nil
 }
 
-func backupDatabasePublic(creds *model.DatabaseCredentials, version string) error {
-	return BackupDatabase(creds, version, "public")
-}
+// PartialExtension marks artifacts that are still being written
+const PartialExtension = ".partial"
+
+// processWaitDelay bounds how long a killed pg_dump may keep its output open
+const processWaitDelay = 10 * time.Second
 
-// to create new backup function, just copy the BackupDatabase function and change the schema name, for example:
-func backupDatabaseNewSchema(creds *model.DatabaseCredentials, version string) error {
-	return BackupDatabase(creds, version, "dblog")
+// runStreamedDump pipes pg_dump's stdout through the job's compressor and
+// encryptor into backupFile, so no cleartext is written to disk
+func runStreamedDump(command *exec.Cmd, backupFile string, job model.BackupJob, transfer *transfer) error {
+	out, err := os.Create(backupFile)
+	if err != nil {
+		return fmt.Errorf("error creating backup file: %v", err)
+	}
+	defer out.Close()
+
+	encryptor, err := encryptFunc.NewWriter(countingWriter{out, transfer}, job.Encryption)
+	if err != nil {
+		return fmt.Errorf("error creating encryptor: %v", err)
+	}
+
+	compressor, err := compressFunc.NewWriter(encryptor, job.Compression, job.CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("error creating compressor: %v", err)
+	}
+
+	command.Stdout = compressor
+	if err = command.Run(); err != nil {
+		compressor.Close()
+		return fmt.Errorf("error during backup: %v", err)
+	}
+
+	if err = compressor.Close(); err != nil {
+		return fmt.Errorf("error finishing compression: %v", err)
+	}
+
+	if err = encryptor.Close(); err != nil {
+		return fmt.Errorf("error finishing encryption: %v", err)
+	}
+
+	// Flush the artifact before it is renamed, pg_dump does the same for --file
+	if err = out.Sync(); err != nil {
+		return fmt.Errorf("error flushing backup file: %v", err)
+	}
+
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("error closing backup file: %v", err)
+	}
+
+	return nil
 }
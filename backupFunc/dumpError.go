@@ -0,0 +1,84 @@
+package backupFunc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// stderrLines is how many of pg_dump's last stderr lines are kept for errors
+const stderrLines = 20
+
+// DumpError is returned when pg_dump fails and carries the end of its stderr
+type DumpError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *DumpError) Error() string {
+	if e.Stderr == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Err, e.Stderr)
+}
+
+func (e *DumpError) Unwrap() error {
+	return e.Err
+}
+
+// lineTail is a writer that keeps the last lines written to it and hands
+// every complete line to onLine when set
+type lineTail struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+	onLine  func(string)
+}
+
+func newLineTail(max int, onLine func(string)) *lineTail {
+	return &lineTail{max: max, onLine: onLine}
+}
+
+func (t *lineTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		index := bytes.IndexByte(t.partial, '\n')
+		if index < 0 {
+			break
+		}
+		t.add(string(t.partial[:index]))
+		t.partial = t.partial[index+1:]
+	}
+	return len(p), nil
+}
+
+func (t *lineTail) add(line string) {
+	line = strings.TrimRight(line, "\r")
+	if line == "" {
+		return
+	}
+	if t.onLine != nil {
+		t.onLine(line)
+	}
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+// String returns the kept lines, including an unterminated last line
+func (t *lineTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+	}
+	return strings.Join(lines, "\n")
+}
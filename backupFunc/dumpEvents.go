@@ -0,0 +1,88 @@
+package backupFunc
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Kinds of events parsed from pg_dump's verbose output
+const (
+	EventCatalog = "catalog"
+	EventTable   = "table"
+	EventWarning = "warning"
+	EventError   = "error"
+	EventOther   = "other"
+)
+
+// DumpEvent is one line of pg_dump or pg_dumpall --verbose output
+type DumpEvent struct {
+	Kind string
+	// Table is set for table events, schema qualified on PostgreSQL 12 and later
+	Table   string
+	Message string
+}
+
+var (
+	toolPrefix   = regexp.MustCompile(`^pg_dump(all)?: `)
+	tableContent = regexp.MustCompile(`^dumping contents of table "?([^"]+)"?$`)
+)
+
+// catalogPrefixes start the lines pg_dump writes while it reads the catalogs
+var catalogPrefixes = []string{"reading ", "identifying ", "finding ", "flagging ", "saving "}
+
+// ParseDumpLine turns a line of verbose output into an event
+func ParseDumpLine(line string) DumpEvent {
+	message := toolPrefix.ReplaceAllString(strings.TrimSpace(line), "")
+	event := DumpEvent{Kind: EventOther, Message: message}
+
+	lower := strings.ToLower(message)
+	switch {
+	case strings.HasPrefix(lower, "error:"), strings.HasPrefix(lower, "fatal:"), strings.HasPrefix(lower, "detail:"):
+		event.Kind = EventError
+	case strings.HasPrefix(lower, "warning:"):
+		event.Kind = EventWarning
+	default:
+		if matches := tableContent.FindStringSubmatch(message); matches != nil {
+			event.Kind = EventTable
+			event.Table = matches[1]
+			break
+		}
+		for _, prefix := range catalogPrefixes {
+			if strings.HasPrefix(message, prefix) {
+				event.Kind = EventCatalog
+				break
+			}
+		}
+	}
+	return event
+}
+
+// progressLog logs the verbose output of one dump as structured progress
+// lines. Catalog reading is logged once, every table as it is dumped, and
+// warnings and errors as they come.
+type progressLog struct {
+	name    string
+	catalog bool
+	tables  int
+}
+
+func newProgressLog(name string) *progressLog {
+	return &progressLog{name: name}
+}
+
+func (p *progressLog) line(line string) {
+	event := ParseDumpLine(line)
+	switch event.Kind {
+	case EventCatalog:
+		if !p.catalog {
+			p.catalog = true
+			log.Printf("progress schema=%q event=%s", p.name, event.Kind)
+		}
+	case EventTable:
+		p.tables++
+		log.Printf("progress schema=%q event=%s table=%q tables=%d", p.name, event.Kind, event.Table, p.tables)
+	case EventWarning, EventError:
+		log.Printf("progress schema=%q event=%s message=%q", p.name, event.Kind, event.Message)
+	}
+}
@@ -0,0 +1,59 @@
+package backupFunc
+
+import (
+	"backup/model"
+	"fmt"
+	"slices"
+)
+
+// compressionRatios are rough artifact sizes relative to the size of the
+// tables on disk, which includes indexes that are dumped as definitions only
+var compressionRatios = map[string]float64{
+	model.CompressionNone: 1.0,
+	model.CompressionGzip: 0.3,
+	model.CompressionZstd: 0.25,
+}
+
+// archiveRatio applies to custom and directory dumps, which pg_dump
+// compresses itself unless the stream is compressed instead
+const archiveRatio = 0.3
+
+// EstimateSize estimates the size of a job's artifact from
+// pg_total_relation_size of the tables it dumps with data
+func EstimateSize(db Queryer, job model.BackupJob) (int64, error) {
+	// Definitions only, the artifact stays small
+	switch {
+	case job.Content == model.ContentSchemaOnly, job.Section == model.SectionPreData, job.Section == model.SectionPostData:
+		return 0, nil
+	}
+
+	rows, err := db.Query(`SELECT c.relname, pg_total_relation_size(c.oid) FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'm')`, job.Schema)
+	if err != nil {
+		return 0, fmt.Errorf("error estimating size of %s: %v", job.Schema, err)
+	}
+	defer rows.Close()
+
+	var total int64
+	for rows.Next() {
+		var table string
+		var size int64
+		if err = rows.Scan(&table, &size); err != nil {
+			return 0, fmt.Errorf("error reading table size: %v", err)
+		}
+		if job.Tables != nil && !slices.Contains(job.Tables, table) || slices.Contains(job.TablesWithoutData, table) {
+			continue
+		}
+		total += size
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("error estimating size of %s: %v", job.Schema, err)
+	}
+
+	ratio := compressionRatios[job.Compression]
+	if job.Compression == model.CompressionNone && (job.Format == model.FormatCustom || job.Format == model.FormatDirectory) {
+		ratio = archiveRatio
+	}
+	return int64(float64(total) * ratio), nil
+}
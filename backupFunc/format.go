@@ -0,0 +1,18 @@
+package backupFunc
+
+import "backup/model"
+
+// FormatExtension returns the file extension used for a pg_dump format.
+// Directory format dumps are directories and get no extension.
+func FormatExtension(format string) string {
+	switch format {
+	case model.FormatCustom:
+		return ".dump"
+	case model.FormatTar:
+		return ".tar"
+	case model.FormatDirectory:
+		return ""
+	default:
+		return ".sql"
+	}
+}
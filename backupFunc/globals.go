@@ -0,0 +1,94 @@
+package backupFunc
+
+import (
+	"backup/manifestFunc"
+	"backup/model"
+	"backup/platformFunc"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PerformGlobalsBackup dumps the cluster globals and adds the artifact to the
+// run manifest. The returned result is reported alongside the schema results.
+func PerformGlobalsBackup(ctx context.Context, creds *model.DatabaseCredentials, binDir string, job model.BackupJob, noRolePasswords bool, manifest *model.Manifest) (model.SchemaResult, error) {
+	result := model.SchemaResult{Kind: model.KindGlobals, Status: model.StatusSucceeded}
+
+	startedAt := time.Now()
+	artifact, err := BackupGlobals(ctx, creds, binDir, job, noRolePasswords, manifest)
+	result.DurationSeconds = time.Since(startedAt).Seconds()
+
+	if err != nil {
+		result.Status = model.StatusFailed
+		if errors.Is(err, context.Canceled) {
+			result.Status = model.StatusCancelled
+		}
+		result.Error = err.Error()
+		var dumpErr *DumpError
+		if errors.As(err, &dumpErr) {
+			result.Error = dumpErr.Err.Error()
+			result.StderrExcerpt = dumpErr.Stderr
+		}
+		log.Printf("Globals %s", result.Status)
+		return result, fmt.Errorf("error backing up globals: %v", err)
+	}
+
+	result.ArtifactPath = artifact.Path
+	result.Bytes = artifact.Size
+	log.Printf("Globals finished in %.1fs", result.DurationSeconds)
+
+	manifest.Artifacts = append(manifest.Artifacts, *artifact)
+	return result, nil
+}
+
+// BackupGlobals dumps roles, role memberships and tablespaces with
+// pg_dumpall --globals-only from the same bin directory as pg_dump
+func BackupGlobals(ctx context.Context, creds *model.DatabaseCredentials, binDir string, job model.BackupJob, noRolePasswords bool, manifest *model.Manifest) (*model.ManifestArtifact, error) {
+	startedAt := time.Now()
+
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	backupFile, err := artifactFile(creds, job, model.KindGlobals, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		fmt.Sprintf("--username=%s", creds.PgUser),
+		fmt.Sprintf("--host=%s", creds.PgHost),
+		fmt.Sprintf("--port=%s", creds.PgPort),
+		fmt.Sprintf("--database=%s", creds.PgDatabase),
+		"--globals-only",
+		"--verbose",
+	}
+	if noRolePasswords {
+		args = append(args, "--no-role-passwords")
+	}
+
+	args, err = runDumpTool(ctx, creds, nil, model.KindGlobals, platformFunc.ToolPath(binDir, "pg_dumpall"), args, backupFile, job)
+	if err != nil {
+		return nil, err
+	}
+
+	size, checksum, err := manifestFunc.HashPath(backupFile)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing backup: %v", err)
+	}
+
+	return &model.ManifestArtifact{
+		Kind:            model.KindGlobals,
+		Path:            backupFile,
+		Size:            size,
+		SHA256:          checksum,
+		StartedAt:       startedAt,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+		Job:             job,
+		Args:            args,
+	}, nil
+}
@@ -0,0 +1,242 @@
+package backupFunc
+
+import (
+	"backup/spaceFunc"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressDrawInterval is how often the live display is redrawn on a terminal
+	progressDrawInterval = time.Second
+	// progressLogInterval is how often progress is logged otherwise
+	progressLogInterval = 30 * time.Second
+)
+
+// transfer is the progress of one running dump
+type transfer struct {
+	name      string
+	estimated int64
+	started   time.Time
+	written   atomic.Int64
+	// path is polled for the size when pg_dump writes the artifact itself
+	path string
+}
+
+// bytes returns how much of the artifact has been written so far
+func (t *transfer) bytes() int64 {
+	if t.path != "" {
+		return pathSize(t.path)
+	}
+	return t.written.Load()
+}
+
+// status describes the transfer as bytes written, throughput and ETA
+func (t *transfer) status(sep string) string {
+	written := t.bytes()
+	elapsed := time.Since(t.started).Seconds()
+
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(written) / elapsed
+	}
+
+	eta := "unknown"
+	if t.estimated > written && rate > 0 {
+		eta = (time.Duration(float64(t.estimated-written)/rate) * time.Second).Round(time.Second).String()
+	}
+
+	estimated := "unknown"
+	if t.estimated > 0 {
+		estimated = spaceFunc.FormatBytes(t.estimated)
+	}
+
+	return strings.Join([]string{
+		"written=" + spaceFunc.FormatBytes(written),
+		"estimated=" + estimated,
+		"rate=" + spaceFunc.FormatBytes(int64(rate)) + "/s",
+		"eta=" + eta,
+	}, sep)
+}
+
+// countingWriter passes writes on to w and adds their size to a transfer
+type countingWriter struct {
+	w        io.Writer
+	transfer *transfer
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.transfer.written.Add(int64(n))
+	return n, err
+}
+
+// pathSize returns the size of a file or of all files in a directory, what
+// cannot be read yet counts as empty
+func pathSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// Progress reports the running dumps of a run. On a terminal a live line is
+// redrawn below the log, otherwise progress is logged periodically.
+type Progress struct {
+	mu        sync.Mutex
+	transfers []*transfer
+	out       *os.File
+	live      bool
+	width     int
+	drawn     int
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// StartProgress starts reporting until Stop is called
+func StartProgress() *Progress {
+	p := &Progress{
+		out:  os.Stderr,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	interval := progressLogInterval
+	if term.IsTerminal(int(p.out.Fd())) {
+		p.live = true
+		interval = progressDrawInterval
+		if width, _, err := term.GetSize(int(p.out.Fd())); err == nil {
+			p.width = width
+		}
+		// Log lines are written above the live line
+		log.SetOutput(p)
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Stop ends reporting and clears the live line
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+
+	if p.live {
+		p.mu.Lock()
+		p.clear()
+		p.mu.Unlock()
+		log.SetOutput(p.out)
+	}
+}
+
+// Write writes a log line, keeping the live line below it
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := p.out.Write(b)
+	p.draw()
+	return n, err
+}
+
+// track starts following a dump, it is not reported when p is nil
+func (p *Progress) track(name, path string, estimated int64) *transfer {
+	t := &transfer{name: name, estimated: estimated, started: time.Now(), path: path}
+	if p == nil {
+		return t
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transfers = append(p.transfers, t)
+	return t
+}
+
+// untrack stops following a dump once it has finished
+func (p *Progress) untrack(t *transfer) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, tracked := range p.transfers {
+		if tracked == t {
+			p.transfers = append(p.transfers[:i], p.transfers[i+1:]...)
+			break
+		}
+	}
+	if p.live {
+		p.clear()
+		p.draw()
+	}
+}
+
+func (p *Progress) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.live {
+		p.clear()
+		p.draw()
+		return
+	}
+	for _, t := range p.transfers {
+		log.Printf("progress schema=%q event=bytes %s", t.name, t.status(" "))
+	}
+}
+
+// draw writes the live line, cut to the terminal width
+func (p *Progress) draw() {
+	if !p.live || len(p.transfers) == 0 {
+		return
+	}
+	var parts []string
+	for _, t := range p.transfers {
+		parts = append(parts, fmt.Sprintf("%s %s", t.name, t.status(" ")))
+	}
+	line := []rune(strings.Join(parts, " | "))
+	if p.width > 1 && len(line) >= p.width {
+		line = line[:p.width-1]
+	}
+	fmt.Fprint(p.out, "\r"+string(line))
+	p.drawn = len(line)
+}
+
+// clear blanks the live line so the next write starts on an empty line
+func (p *Progress) clear() {
+	if p.drawn == 0 {
+		return
+	}
+	fmt.Fprint(p.out, "\r"+strings.Repeat(" ", p.drawn)+"\r")
+	p.drawn = 0
+}
@@ -0,0 +1,65 @@
+package backupFunc
+
+import (
+	"backup/model"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"slices"
+)
+
+// Queryer is implemented by both *sql.DB and *sql.Tx
+type Queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// CountRows returns the exact number of rows of every table in a schema
+func CountRows(db Queryer, schema string) (map[string]int64, error) {
+	rows, err := db.Query(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = $1 AND table_type = 'BASE TABLE' ORDER BY table_name`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tables of schema %s: %v", schema, err)
+	}
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error reading table name: %v", err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing tables of schema %s: %v", schema, err)
+	}
+
+	counts := map[string]int64{}
+	for _, table := range tables {
+		var count int64
+		query := fmt.Sprintf("SELECT count(*) FROM %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table))
+		if err = db.QueryRow(query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("error counting rows of %s.%s: %v", schema, table, err)
+		}
+		counts[table] = count
+	}
+	return counts, nil
+}
+
+// DumpedRowCounts limits row counts to the tables a job dumps. Tables dumped
+// without data restore empty, so they are expected to have no rows
+func DumpedRowCounts(counts map[string]int64, job model.BackupJob) map[string]int64 {
+	dumped := map[string]int64{}
+	for table, count := range counts {
+		if job.Tables != nil && !slices.Contains(job.Tables, table) {
+			continue
+		}
+		if slices.Contains(job.TablesWithoutData, table) {
+			count = 0
+		}
+		dumped[table] = count
+	}
+	return dumped
+}
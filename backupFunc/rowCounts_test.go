@@ -0,0 +1,49 @@
+package backupFunc
+
+import (
+	"backup/model"
+	"reflect"
+	"testing"
+)
+
+func TestDumpedRowCounts(t *testing.T) {
+	counts := map[string]int64{"users": 10, "orders": 25, "log_2024": 500, "log_2025": 300}
+
+	tests := []struct {
+		name string
+		job  model.BackupJob
+		want map[string]int64
+	}{
+		{
+			name: "no table rules",
+			job:  model.BackupJob{},
+			want: map[string]int64{"users": 10, "orders": 25, "log_2024": 500, "log_2025": 300},
+		},
+		{
+			name: "excluded tables",
+			job:  model.BackupJob{Tables: []string{"orders", "users"}},
+			want: map[string]int64{"users": 10, "orders": 25},
+		},
+		{
+			name: "tables without data",
+			job: model.BackupJob{
+				Tables:            []string{"log_2024", "log_2025", "orders", "users"},
+				TablesWithoutData: []string{"log_2024", "log_2025"},
+			},
+			want: map[string]int64{"users": 10, "orders": 25, "log_2024": 0, "log_2025": 0},
+		},
+		{
+			name: "included views are not counted",
+			job:  model.BackupJob{Tables: []string{"log_2025", "log_summary"}, TablesWithoutData: []string{"log_2025"}},
+			want: map[string]int64{"log_2025": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DumpedRowCounts(counts, test.job); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("DumpedRowCounts() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package backupFunc
+
+import (
+	"backup/config/dbconfig"
+	"backup/model"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Snapshot is a REPEATABLE READ transaction whose snapshot is exported to
+// pg_dump with --snapshot, so every dump of a database sees the same point
+// in time. The snapshot is only valid while the transaction is open.
+type Snapshot struct {
+	ID string
+	// Tx reads from the exported snapshot, e.g. to count rows
+	Tx   *sql.Tx
+	conn *sql.Conn
+	db   *sql.DB
+}
+
+// ExportSnapshot opens a dedicated connection to the database, starts a
+// REPEATABLE READ transaction on it and exports its snapshot. Close must be
+// called once every dump using the snapshot has finished.
+func ExportSnapshot(creds *model.DatabaseCredentials, database string) (*Snapshot, error) {
+	databaseCreds := *creds
+	databaseCreds.PgDatabase = database
+	db, err := dbconfig.CheckDatabaseConnection(&databaseCreds)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening snapshot connection: %v", err)
+	}
+
+	// The transaction sits idle while the dumps run, so it must not be timed out.
+	// Servers older than 9.6 do not know the setting, which is fine.
+	_, _ = conn.ExecContext(ctx, "SET idle_in_transaction_session_timeout = 0")
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		conn.Close()
+		db.Close()
+		return nil, fmt.Errorf("error starting snapshot transaction: %v", err)
+	}
+
+	snapshot := &Snapshot{Tx: tx, conn: conn, db: db}
+	if err = tx.QueryRow("SELECT pg_export_snapshot()").Scan(&snapshot.ID); err != nil {
+		snapshot.Close()
+		return nil, fmt.Errorf("error exporting snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// Close ends the snapshot transaction and its connection
+func (s *Snapshot) Close() error {
+	err := s.Tx.Rollback()
+	s.conn.Close()
+	s.db.Close()
+	if err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("error closing snapshot transaction: %v", err)
+	}
+	return nil
+}
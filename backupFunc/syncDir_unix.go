@@ -0,0 +1,15 @@
+//go:build !windows
+
+package backupFunc
+
+import "os"
+
+// syncDir flushes a directory so a rename inside it survives a crash
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package backupFunc
+
+// syncDir does nothing, directories cannot be flushed on Windows and NTFS
+// journals renames itself
+func syncDir(dir string) error {
+	return nil
+}
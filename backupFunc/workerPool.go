@@ -0,0 +1,97 @@
+package backupFunc
+
+import (
+	"backup/model"
+	"log"
+	"sort"
+	"sync"
+)
+
+// workerPool starts queued jobs in priority order while keeping the number
+// of running jobs under a global limit and a limit per database host
+type workerPool struct {
+	mu            sync.Mutex
+	cond          *sync.Cond
+	maxGlobal     int
+	maxPerHost    int
+	running       int
+	runningByHost map[string]int
+}
+
+func newWorkerPool(maxGlobal, maxPerHost int) *workerPool {
+	if maxGlobal <= 0 {
+		maxGlobal = model.DefaultMaxParallel
+	}
+	if maxPerHost <= 0 || maxPerHost > maxGlobal {
+		maxPerHost = maxGlobal
+	}
+
+	pool := &workerPool{
+		maxGlobal:     maxGlobal,
+		maxPerHost:    maxPerHost,
+		runningByHost: map[string]int{},
+	}
+	pool.cond = sync.NewCond(&pool.mu)
+	return pool
+}
+
+// run calls work for every job index, highest priority first, and returns
+// once all of them have finished. hostOf names the host a job runs against.
+func (p *workerPool) run(jobs []model.BackupJob, hostOf func(model.BackupJob) string, work func(int)) {
+	queue := make([]int, len(jobs))
+	for i := range queue {
+		queue[i] = i
+	}
+	sort.SliceStable(queue, func(a, b int) bool {
+		return jobs[queue[a]].Priority > jobs[queue[b]].Priority
+	})
+
+	var wg sync.WaitGroup
+	for len(queue) > 0 {
+		p.mu.Lock()
+		position := -1
+		for position < 0 {
+			position = p.nextRunnable(queue, jobs, hostOf)
+			if position < 0 {
+				p.cond.Wait()
+			}
+		}
+
+		index := queue[position]
+		queue = append(queue[:position], queue[position+1:]...)
+		host := hostOf(jobs[index])
+		p.running++
+		p.runningByHost[host]++
+		log.Printf("Starting schema %s on %s (priority %d): %d running, %d queued",
+			JobName(jobs[index]), host, jobs[index].Priority, p.running, len(queue))
+		p.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(index)
+
+			p.mu.Lock()
+			p.running--
+			p.runningByHost[host]--
+			p.cond.Broadcast()
+			p.mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// nextRunnable returns the queue position of the first job that fits the
+// limits, or -1 when every queued job has to wait. Callers hold p.mu.
+func (p *workerPool) nextRunnable(queue []int, jobs []model.BackupJob, hostOf func(model.BackupJob) string) int {
+	if p.running >= p.maxGlobal {
+		return -1
+	}
+	for position, index := range queue {
+		if p.runningByHost[hostOf(jobs[index])] < p.maxPerHost {
+			return position
+		}
+	}
+	return -1
+}
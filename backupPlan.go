@@ -0,0 +1,188 @@
+package main
+
+import (
+	"backup/backupFunc"
+	"backup/config/backupConfig"
+	"backup/config/dbconfig"
+	"backup/config/locateTools"
+	"backup/config/schemaDiscovery"
+	"backup/model"
+	"backup/spaceFunc"
+	"backup/versionFunc"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// planBackupJobs resolves the databases and schemas to back up into jobs.
+// In cluster mode every database on the server is included.
+func planBackupJobs(config *model.BackupConfig, creds *model.DatabaseCredentials, db *sql.DB) ([]model.BackupJob, error) {
+	databases := []string{creds.PgDatabase}
+	if config.Cluster {
+		discovered, err := schemaDiscovery.DiscoverDatabases(db, config.ExcludeDatabases)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering databases: %v", err)
+		}
+		databases = discovered
+		log.Printf("Databases to back up: %s", strings.Join(databases, ", "))
+	}
+
+	var jobs []model.BackupJob
+	for _, database := range databases {
+		err := withDatabase(creds, db, database, func(databaseDB *sql.DB) error {
+			schemas, err := schemaDiscovery.DiscoverSchemas(databaseDB, config.Schemas)
+			if err != nil {
+				return fmt.Errorf("error discovering schemas of %s: %v", database, err)
+			}
+			log.Printf("Schemas to back up in %s: %s", database, strings.Join(schemas, ", "))
+
+			databaseJobs, err := backupConfig.ResolveJobs(config, database, schemas)
+			if err != nil {
+				return fmt.Errorf("error resolving backup jobs: %v", err)
+			}
+
+			// Resolve table rules now so the dry run and the manifest show the actual tables
+			for i, job := range databaseJobs {
+				rules := job.TableRules
+				if len(rules.Include) == 0 && len(rules.Exclude) == 0 && len(rules.ExcludeData) == 0 {
+					continue
+				}
+				tables, withoutData, excluded, err := schemaDiscovery.DiscoverTables(databaseDB, job.Schema, rules)
+				if err != nil {
+					return err
+				}
+				databaseJobs[i].Tables = tables
+				databaseJobs[i].TablesWithoutData = withoutData
+				databaseJobs[i].ExcludedTables = excluded
+			}
+
+			// Estimate sizes for the disk space check and progress reporting
+			for i, job := range databaseJobs {
+				if databaseJobs[i].EstimatedBytes, err = backupFunc.EstimateSize(databaseDB, job); err != nil {
+					return err
+				}
+			}
+			jobs = append(jobs, databaseJobs...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// checkSpace compares the estimated size of all artifacts with the free space
+// below the output root
+func checkSpace(config *model.BackupConfig, jobs []model.BackupJob) error {
+	var required int64
+	for _, job := range jobs {
+		required += job.EstimatedBytes
+	}
+	return spaceFunc.Check(config.OutputRoot, required, config.SpaceWarnPercent)
+}
+
+// exportSnapshots exports one snapshot per database and hands its ID to the
+// database's jobs. A database whose snapshot cannot be exported, e.g. on a
+// standby, falls back to each pg_dump taking its own snapshot.
+func exportSnapshots(creds *model.DatabaseCredentials, jobs []model.BackupJob) map[string]*backupFunc.Snapshot {
+	snapshots := map[string]*backupFunc.Snapshot{}
+	for i, job := range jobs {
+		snapshot, exported := snapshots[job.Database]
+		if !exported {
+			var err error
+			snapshot, err = backupFunc.ExportSnapshot(creds, job.Database)
+			if err != nil {
+				log.Printf("Warning: %v, schemas of %s are dumped without a shared snapshot", err, job.Database)
+			} else {
+				log.Printf("Exported snapshot %s for database %s", snapshot.ID, job.Database)
+			}
+			snapshots[job.Database] = snapshot
+		}
+		if snapshot != nil {
+			jobs[i].Snapshot = snapshot.ID
+		}
+	}
+	return snapshots
+}
+
+// closeSnapshots ends the snapshot transactions once every dump has finished
+func closeSnapshots(snapshots map[string]*backupFunc.Snapshot) {
+	for database, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		if err := snapshot.Close(); err != nil {
+			log.Printf("Warning: snapshot of %s: %v", database, err)
+		}
+	}
+}
+
+// recordRowCounts counts the rows of every table the jobs dump, keyed by
+// "<database>.<schema>". Counts are read from the database's exported
+// snapshot when there is one, so they match what pg_dump sees.
+func recordRowCounts(creds *model.DatabaseCredentials, db *sql.DB, jobs []model.BackupJob, snapshots map[string]*backupFunc.Snapshot) (map[string]map[string]int64, error) {
+	rowCounts := map[string]map[string]int64{}
+	for _, job := range jobs {
+		if _, counted := rowCounts[job.Database+"."+job.Schema]; counted {
+			continue
+		}
+		if snapshot := snapshots[job.Database]; snapshot != nil {
+			counts, err := backupFunc.CountRows(snapshot.Tx, job.Schema)
+			if err != nil {
+				return nil, err
+			}
+			rowCounts[job.Database+"."+job.Schema] = backupFunc.DumpedRowCounts(counts, job)
+			continue
+		}
+		err := withDatabase(creds, db, job.Database, func(databaseDB *sql.DB) error {
+			counts, err := backupFunc.CountRows(databaseDB, job.Schema)
+			rowCounts[job.Database+"."+job.Schema] = backupFunc.DumpedRowCounts(counts, job)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rowCounts, nil
+}
+
+// withDatabase calls fn with a connection to database, reusing db when it is
+// already connected to it
+func withDatabase(creds *model.DatabaseCredentials, db *sql.DB, database string, fn func(*sql.DB) error) error {
+	if database == creds.PgDatabase {
+		return fn(db)
+	}
+
+	databaseCreds := *creds
+	databaseCreds.PgDatabase = database
+	databaseDB, err := dbconfig.CheckDatabaseConnection(&databaseCreds)
+	if err != nil {
+		return fmt.Errorf("error connecting to database %s: %v", database, err)
+	}
+	defer databaseDB.Close()
+
+	return fn(databaseDB)
+}
+
+// probeTargetTools checks the client tools directories set on targets that
+// differ from the run's, so an unusable one fails the run before any dump
+func probeTargetTools(config *model.BackupConfig, jobs []model.BackupJob, server versionFunc.Version) ([]model.ClientTool, error) {
+	var tools []model.ClientTool
+	probed := map[string]bool{config.PgBinDir: true, "": true}
+	for _, job := range jobs {
+		if probed[job.PgBinDir] {
+			continue
+		}
+		probed[job.PgBinDir] = true
+
+		installation, err := locateTools.FromDir(job.PgBinDir, server)
+		if err != nil {
+			return nil, fmt.Errorf("error checking client tools for %s: %v", backupFunc.JobName(job), err)
+		}
+		log.Printf("Using PostgreSQL %s client tools in %s for %s", installation.Version, installation.BinDir, backupFunc.JobName(job))
+		tools = append(tools, installation.Tools...)
+	}
+	return tools, nil
+}
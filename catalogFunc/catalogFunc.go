@@ -0,0 +1,312 @@
+package catalogFunc
+
+import (
+	"backup/backupFunc"
+	"backup/compressFunc"
+	"backup/encryptFunc"
+	"backup/manifestFunc"
+	"backup/model"
+	"backup/namingFunc"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// sectionKinds are the artifacts of a split-sections dump in restore order
+var sectionKinds = []string{model.KindPreData, model.KindDataSection, model.KindPostData}
+
+var formats = []string{model.FormatPlain, model.FormatCustom, model.FormatTar, model.FormatDirectory}
+
+var compressions = []string{model.CompressionGzip, model.CompressionZstd}
+
+// List returns every backup artifact below the output root, oldest first.
+// Names are parsed with the configured naming template.
+func List(storage model.StorageConfig) ([]model.CatalogEntry, error) {
+	var entries []model.CatalogEntry
+
+	templates, err := namingFunc.Templates(storage.NameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	root := storage.OutputRoot
+	if _, err = os.Stat(root); os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		entry, ok := parseArtifact(templates, path, filepath.ToSlash(relative), d.IsDir(), true)
+		if !ok {
+			return nil
+		}
+		entries = append(entries, entry)
+
+		// Directory format dumps are a single artifact
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing backups in %s: %v", root, err)
+	}
+
+	if err = annotateFromManifests(root, entries); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, nil
+}
+
+// annotateFromManifests fills in the run and the schema recorded by the run
+// manifests, which are more reliable than the names on disk
+func annotateFromManifests(root string, entries []model.CatalogEntry) error {
+	manifests, err := manifestFunc.LoadAll(root)
+	if err != nil {
+		return err
+	}
+
+	for manifestPath, manifest := range manifests {
+		for _, artifact := range manifest.Artifacts {
+			artifactPath := filepath.Clean(manifestFunc.ArtifactPath(manifestPath, artifact))
+			for i := range entries {
+				if filepath.Clean(entries[i].Path) != artifactPath {
+					continue
+				}
+				entries[i].RunID = manifest.RunID
+				entries[i].ManifestPath = manifestPath
+				entries[i].Schema = artifact.Schema
+				entries[i].Database = artifact.Database
+			}
+		}
+	}
+	return nil
+}
+
+// Find resolves a backup reference to its catalog entry. The reference is
+// a path to an artifact, a catalog ID as printed by List, <run ID>/<schema>,
+// <run ID>/<database>/<schema> or <run ID>/globals.
+func Find(storage model.StorageConfig, reference string) (model.CatalogEntry, error) {
+	entries, err := List(storage)
+	if err != nil {
+		return model.CatalogEntry{}, err
+	}
+
+	if entry, ok := parseExisting(storage, reference); ok {
+		// Prefer the catalog's view, which includes the manifest details
+		for _, listed := range entries {
+			if filepath.Clean(listed.Path) == filepath.Clean(reference) {
+				return listed, nil
+			}
+		}
+		return entry, nil
+	}
+
+	var matches []model.CatalogEntry
+	for _, entry := range entries {
+		if entry.ID == reference || filepath.Base(entry.ID) == reference ||
+			(entry.RunID != "" && entry.Kind == model.KindGlobals && entry.RunID+"/"+model.KindGlobals == reference) ||
+			(entry.RunID != "" && entry.RunID+"/"+entry.Schema == reference) ||
+			(entry.RunID != "" && entry.RunID+"/"+entry.Database+"/"+entry.Schema == reference) {
+			matches = append(matches, entry)
+		}
+	}
+
+	// The sections of one split dump are found through their first section
+	if first, ok := firstSection(matches); ok {
+		return first, nil
+	}
+
+	switch len(matches) {
+	case 0:
+		return model.CatalogEntry{}, fmt.Errorf("no backup found for %q", reference)
+	case 1:
+		return matches[0], nil
+	default:
+		return model.CatalogEntry{}, fmt.Errorf("%q matches %d backups, use the full catalog ID", reference, len(matches))
+	}
+}
+
+// IsSection reports whether the artifact is one section of a split dump
+func IsSection(entry model.CatalogEntry) bool {
+	return slices.Contains(sectionKinds, entry.Kind)
+}
+
+// Sections returns the pre-data, data and post-data artifacts of the split
+// dump entry belongs to, in restore order
+func Sections(storage model.StorageConfig, entry model.CatalogEntry) ([]model.CatalogEntry, error) {
+	entries, err := List(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []model.CatalogEntry
+	for _, kind := range sectionKinds {
+		found := false
+		for _, listed := range entries {
+			if listed.Kind == kind && sameDump(listed, entry) {
+				sections = append(sections, listed)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("split dump %s is incomplete, its %s section is missing", entry.ID, kind)
+		}
+	}
+	return sections, nil
+}
+
+// firstSection returns the pre-data artifact when every match is a section
+// of the same split dump
+func firstSection(matches []model.CatalogEntry) (model.CatalogEntry, bool) {
+	if len(matches) < 2 {
+		return model.CatalogEntry{}, false
+	}
+	for _, match := range matches {
+		if !IsSection(match) || !sameDump(match, matches[0]) {
+			return model.CatalogEntry{}, false
+		}
+	}
+	for _, match := range matches {
+		if match.Kind == model.KindPreData {
+			return match, true
+		}
+	}
+	return model.CatalogEntry{}, false
+}
+
+// sameDump reports whether two artifacts belong to the same series and
+// were taken at the same time
+func sameDump(a, b model.CatalogEntry) bool {
+	return a.Series == b.Series && a.Timestamp.Equal(b.Timestamp)
+}
+
+// FindGlobals returns the globals artifact taken by the same run as entry.
+// Runs are matched by run ID, or by timestamp for artifacts without a manifest.
+func FindGlobals(storage model.StorageConfig, entry model.CatalogEntry) (model.CatalogEntry, bool, error) {
+	entries, err := List(storage)
+	if err != nil {
+		return model.CatalogEntry{}, false, err
+	}
+
+	for _, listed := range entries {
+		if listed.Kind != model.KindGlobals {
+			continue
+		}
+		if entry.RunID != "" && listed.RunID == entry.RunID {
+			return listed, true, nil
+		}
+		if entry.RunID == "" && listed.Timestamp.Equal(entry.Timestamp) {
+			return listed, true, nil
+		}
+	}
+	return model.CatalogEntry{}, false, nil
+}
+
+// ParseArtifact reads the kind, schema, timestamp, format, compression and
+// encryption of an artifact from its path. Paths below the output root are
+// parsed relative to it, other paths by their trailing components.
+func ParseArtifact(storage model.StorageConfig, path string, isDir bool) (model.CatalogEntry, bool) {
+	templates, err := namingFunc.Templates(storage.NameTemplate)
+	if err != nil {
+		return model.CatalogEntry{}, false
+	}
+
+	if relative, err := filepath.Rel(storage.OutputRoot, path); err == nil && filepath.IsLocal(relative) {
+		return parseArtifact(templates, path, filepath.ToSlash(relative), isDir, true)
+	}
+	return parseArtifact(templates, path, filepath.ToSlash(path), isDir, false)
+}
+
+// parseArtifact strips the extensions from name and parses the rest with the
+// first template that matches. Names relative to the output root must match
+// whole, other paths only need to end in a templated name. The ID is the
+// matched name without extensions.
+func parseArtifact(templates []*namingFunc.Template, path, name string, isDir, relative bool) (model.CatalogEntry, bool) {
+	entry := model.CatalogEntry{
+		Path:        path,
+		Compression: model.CompressionNone,
+	}
+
+	if strings.HasSuffix(name, encryptFunc.Extension) {
+		entry.Encrypted = true
+		name = strings.TrimSuffix(name, encryptFunc.Extension)
+	}
+
+	for _, compression := range compressions {
+		if strings.HasSuffix(name, compressFunc.Extension(compression)) {
+			entry.Compression = compression
+			name = strings.TrimSuffix(name, compressFunc.Extension(compression))
+			break
+		}
+	}
+
+	if isDir {
+		entry.Format = model.FormatDirectory
+	} else {
+		for _, format := range formats {
+			extension := backupFunc.FormatExtension(format)
+			if extension != "" && strings.HasSuffix(name, extension) {
+				entry.Format = format
+				name = strings.TrimSuffix(name, extension)
+				break
+			}
+		}
+		if entry.Format == "" {
+			return model.CatalogEntry{}, false
+		}
+	}
+
+	for _, template := range templates {
+		values, matched, ok := template.Match(name)
+		if !ok || relative && matched != name {
+			continue
+		}
+
+		entry.ID = matched
+		entry.Kind = values.Kind
+		entry.Schema = values.Schema
+		entry.Database = values.Database
+		entry.RunID = values.RunID
+		entry.Timestamp = values.Time
+		entry.Series = template.Series(values)
+
+		var parts []string
+		for _, part := range []string{values.Database, values.Host, values.Port} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		entry.DataSource = strings.Join(parts, "_")
+		return entry, true
+	}
+	return model.CatalogEntry{}, false
+}
+
+func parseExisting(storage model.StorageConfig, path string) (model.CatalogEntry, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return model.CatalogEntry{}, false
+	}
+	return ParseArtifact(storage, path, info.IsDir())
+}
@@ -0,0 +1,91 @@
+package compressFunc
+
+import (
+	"backup/model"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+// Extension returns the file extension added by a compression algorithm
+func Extension(algorithm string) string {
+	switch algorithm {
+	case model.CompressionGzip:
+		return ".gz"
+	case model.CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// Validate checks that the algorithm is known and the level is in its range.
+// Level 0 always means the algorithm's default level.
+func Validate(algorithm string, level int) error {
+	switch algorithm {
+	case model.CompressionNone:
+		if level != 0 {
+			return fmt.Errorf("compression level set without a compression algorithm")
+		}
+	case model.CompressionGzip:
+		if level < 0 || level > gzip.BestCompression {
+			return fmt.Errorf("gzip level must be between 1 and %d", gzip.BestCompression)
+		}
+	case model.CompressionZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("zstd level must be between 1 and 22")
+		}
+	default:
+		return fmt.Errorf("unknown compression %q", algorithm)
+	}
+	return nil
+}
+
+// NewWriter wraps w so everything written to it is compressed. Closing the
+// returned writer flushes the compressor but does not close w.
+func NewWriter(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch algorithm {
+	case model.CompressionNone:
+		return nopCloser{w}, nil
+	case model.CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case model.CompressionZstd:
+		options := []zstd.EOption{}
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, options...)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algorithm)
+	}
+}
+
+// NewReader returns a reader that decompresses r with the given algorithm
+func NewReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case model.CompressionNone:
+		return io.NopCloser(r), nil
+	case model.CompressionGzip:
+		return gzip.NewReader(r)
+	case model.CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algorithm)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
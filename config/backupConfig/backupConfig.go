@@ -0,0 +1,476 @@
+package backupConfig
+
+import (
+	"backup/compressFunc"
+	"backup/encryptFunc"
+	"backup/model"
+	"backup/namingFunc"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScanBackupConfig builds the backup configuration. Settings are read from the
+// JSON config file first, then overridden by environment variables and finally
+// by command line flags.
+func ScanBackupConfig(args []string) (*model.BackupConfig, error) {
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("BACKUP_CONFIG"), "path to a JSON config file")
+	outputRoot := flags.String("output-root", "", "absolute directory backups are written to")
+	nameTemplate := flags.String("name-template", "", "artifact name below the output root, e.g. {db}/{schema}/{ts}")
+	cluster := flags.Bool("cluster", false, "back up every database on the server")
+	excludeDatabases := flags.String("exclude-databases", "", "comma separated database glob patterns skipped in cluster mode")
+	schemas := flags.String("schemas", "", "comma separated schema names or glob patterns, \"all\" for every schema, \"!pattern\" to exclude")
+	format := flags.String("format", "", "pg_dump format: plain, custom, directory or tar")
+	jobs := flags.Int("jobs", 0, "number of parallel pg_dump jobs, directory format only")
+	content := flags.String("content", "", "dump content: full, schema-only, data-only or split-sections")
+	compression := flags.String("compress", "", "stream compression: none, gzip or zstd")
+	compressionLevel := flags.Int("compress-level", 0, "compression level, 0 for the default")
+	recipients := flags.String("encrypt-recipients", "", "comma separated age public keys to encrypt artifacts for")
+	keepLast := flags.Int("keep-last", 0, "retention: keep the newest N backups")
+	keepDaily := flags.Int("keep-daily", 0, "retention: keep one backup per day for N days")
+	keepWeekly := flags.Int("keep-weekly", 0, "retention: keep one backup per week for N weeks")
+	keepMonthly := flags.Int("keep-monthly", 0, "retention: keep one backup per month for N months")
+	prune := flags.Bool("prune", false, "apply the retention policy after a successful backup")
+	maxParallel := flags.Int("max-parallel", 0, "maximum number of schemas dumped at once")
+	maxParallelPerHost := flags.Int("max-parallel-per-host", 0, "maximum number of schemas dumped at once from one server")
+	failurePolicy := flags.String("on-failure", "", "when a schema fails: continue the others or cancel them")
+	schemaTimeout := flags.String("schema-timeout", "", "time limit for dumping one schema, e.g. 30m")
+	rowCounts := flags.Bool("row-counts", false, "record per-table row counts in the manifest for verify")
+	globals := flags.Bool("globals", false, "also dump roles, memberships and tablespaces with pg_dumpall")
+	noRolePasswords := flags.Bool("no-role-passwords", false, "leave role passwords out of the globals dump")
+	spaceWarnPercent := flags.Int("space-warn-percent", 0, "warn when the backup is estimated to use more than this percentage of the free space")
+	skipSpaceCheck := flags.Bool("skip-space-check", false, "do not compare the estimated backup size with the free disk space")
+	pgBinDir := flags.String("pg-bin", "", "directory of the client tools to use, skips detection and installation")
+	dryRun := flags.Bool("dry-run", false, "only report what would be done")
+	flags.Bool("elevated", false, "internal: set when the process was relaunched with admin privileges")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("error parsing flags: %v", err)
+	}
+
+	config := model.BackupConfig{}
+
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyStorage(&config.StorageConfig, *outputRoot, *nameTemplate); err != nil {
+		return nil, err
+	}
+
+	if err := applyBool(&config.Cluster, "BACKUP_CLUSTER", *cluster); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("BACKUP_EXCLUDE_DATABASES"); value != "" {
+		config.ExcludeDatabases = splitList(value)
+	}
+	if *excludeDatabases != "" {
+		config.ExcludeDatabases = splitList(*excludeDatabases)
+	}
+
+	if value := os.Getenv("BACKUP_SCHEMAS"); value != "" {
+		config.Schemas = splitList(value)
+	}
+	if *schemas != "" {
+		config.Schemas = splitList(*schemas)
+	}
+	if len(config.Schemas) == 0 {
+		config.Schemas = splitList(model.DefaultSchemas)
+	}
+
+	applyString(&config.Format, "BACKUP_FORMAT", *format)
+	if config.Format == "" {
+		config.Format = model.FormatPlain
+	}
+
+	if err := applyInt(&config.Jobs, "BACKUP_JOBS", *jobs); err != nil {
+		return nil, err
+	}
+
+	applyString(&config.Content, "BACKUP_CONTENT", *content)
+	if config.Content == "" {
+		config.Content = model.ContentFull
+	}
+
+	applyString(&config.Compression, "BACKUP_COMPRESSION", *compression)
+	if config.Compression == "" {
+		config.Compression = model.CompressionNone
+	}
+
+	if err := applyInt(&config.CompressionLevel, "BACKUP_COMPRESSION_LEVEL", *compressionLevel); err != nil {
+		return nil, err
+	}
+
+	retention := []struct {
+		setting *int
+		envName string
+		value   int
+	}{
+		{&config.Retention.KeepLast, "BACKUP_KEEP_LAST", *keepLast},
+		{&config.Retention.KeepDaily, "BACKUP_KEEP_DAILY", *keepDaily},
+		{&config.Retention.KeepWeekly, "BACKUP_KEEP_WEEKLY", *keepWeekly},
+		{&config.Retention.KeepMonthly, "BACKUP_KEEP_MONTHLY", *keepMonthly},
+	}
+	for _, rule := range retention {
+		if err := applyInt(rule.setting, rule.envName, rule.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyBool(&config.PruneAfterBackup, "BACKUP_PRUNE_AFTER_BACKUP", *prune); err != nil {
+		return nil, err
+	}
+	if err := applyInt(&config.MaxParallel, "BACKUP_MAX_PARALLEL", *maxParallel); err != nil {
+		return nil, err
+	}
+	if config.MaxParallel <= 0 {
+		config.MaxParallel = model.DefaultMaxParallel
+	}
+	if err := applyInt(&config.MaxParallelPerHost, "BACKUP_MAX_PARALLEL_PER_HOST", *maxParallelPerHost); err != nil {
+		return nil, err
+	}
+
+	applyString(&config.FailurePolicy, "BACKUP_FAILURE_POLICY", *failurePolicy)
+	switch config.FailurePolicy {
+	case "":
+		config.FailurePolicy = model.FailureContinue
+	case model.FailureContinue, model.FailureCancel:
+	default:
+		return nil, fmt.Errorf("unknown failure policy %q, expected continue or cancel", config.FailurePolicy)
+	}
+
+	applyString(&config.SchemaTimeout, "BACKUP_SCHEMA_TIMEOUT", *schemaTimeout)
+
+	if err := applyBool(&config.RecordRowCounts, "BACKUP_RECORD_ROW_COUNTS", *rowCounts); err != nil {
+		return nil, err
+	}
+	if err := applyBool(&config.Globals, "BACKUP_GLOBALS", *globals); err != nil {
+		return nil, err
+	}
+	if err := applyBool(&config.NoRolePasswords, "BACKUP_NO_ROLE_PASSWORDS", *noRolePasswords); err != nil {
+		return nil, err
+	}
+	if err := applyInt(&config.SpaceWarnPercent, "BACKUP_SPACE_WARN_PERCENT", *spaceWarnPercent); err != nil {
+		return nil, err
+	}
+	if config.SpaceWarnPercent <= 0 {
+		config.SpaceWarnPercent = model.DefaultSpaceWarnPercent
+	}
+	if err := applyBool(&config.SkipSpaceCheck, "BACKUP_SKIP_SPACE_CHECK", *skipSpaceCheck); err != nil {
+		return nil, err
+	}
+	applyString(&config.PgBinDir, "PG_BIN_DIR", *pgBinDir)
+	config.DryRun = *dryRun
+
+	if value := os.Getenv("BACKUP_ENCRYPT_RECIPIENTS"); value != "" {
+		config.Encryption.Recipients = splitList(value)
+	}
+	if *recipients != "" {
+		config.Encryption.Recipients = splitList(*recipients)
+	}
+	// The passphrase is only read from the environment so it never shows up in process lists
+	config.Encryption.Passphrase = os.Getenv("BACKUP_ENCRYPT_PASSPHRASE")
+
+	if err := encryptFunc.Validate(config.Encryption); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// StorageFlags adds the flags locating existing backups to a command's flag
+// set. The returned function resolves the settings once the flags are parsed,
+// reading the config file, then environment variables, then the flags.
+func StorageFlags(flags *flag.FlagSet) func() (model.StorageConfig, error) {
+	_ = godotenv.Load()
+
+	configFile := flags.String("config", os.Getenv("BACKUP_CONFIG"), "path to a JSON config file")
+	outputRoot := flags.String("output-root", "", "absolute directory backups are written to")
+	nameTemplate := flags.String("name-template", "", "artifact name below the output root")
+
+	return func() (model.StorageConfig, error) {
+		config := model.BackupConfig{}
+		if *configFile != "" {
+			if err := loadConfigFile(*configFile, &config); err != nil {
+				return model.StorageConfig{}, err
+			}
+		}
+
+		err := applyStorage(&config.StorageConfig, *outputRoot, *nameTemplate)
+		return config.StorageConfig, err
+	}
+}
+
+// PgBinFlag adds the --pg-bin flag to a command's flag set, defaulting to
+// the PG_BIN_DIR environment variable
+func PgBinFlag(flags *flag.FlagSet) *string {
+	_ = godotenv.Load()
+
+	return flags.String("pg-bin", os.Getenv("PG_BIN_DIR"), "directory of the client tools to use, skips detection and installation")
+}
+
+// applyStorage overrides the storage settings with environment variables and
+// flags, then checks them. The default root is made absolute so the logs
+// show where backups actually go.
+func applyStorage(storage *model.StorageConfig, outputRoot, nameTemplate string) error {
+	applyString(&storage.OutputRoot, "BACKUP_OUTPUT_ROOT", outputRoot)
+	applyString(&storage.NameTemplate, "BACKUP_NAME_TEMPLATE", nameTemplate)
+
+	if storage.OutputRoot == "" {
+		root, err := filepath.Abs(model.BackupsDir)
+		if err != nil {
+			return fmt.Errorf("error resolving backups directory: %v", err)
+		}
+		storage.OutputRoot = root
+	} else if !filepath.IsAbs(storage.OutputRoot) {
+		return fmt.Errorf("output root %q must be an absolute path", storage.OutputRoot)
+	}
+
+	if _, err := namingFunc.Templates(storage.NameTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ScanDecryptionConfig reads the settings used to decrypt artifacts
+func ScanDecryptionConfig(identityFile string) model.DecryptionConfig {
+	_ = godotenv.Load()
+
+	config := model.DecryptionConfig{
+		IdentityFile: os.Getenv("BACKUP_DECRYPT_IDENTITY_FILE"),
+		Passphrase:   os.Getenv("BACKUP_ENCRYPT_PASSPHRASE"),
+	}
+	if identityFile != "" {
+		config.IdentityFile = identityFile
+	}
+	return config
+}
+
+// ResolveJobs builds one backup job per schema of a database, applying the
+// first target whose pattern matches the schema on top of the configured
+// defaults. Without a naming template, each database gets its own output
+// subtree in cluster mode.
+// Split-sections schemas get one job per section.
+func ResolveJobs(config *model.BackupConfig, database string, schemas []string) ([]model.BackupJob, error) {
+	template, err := namingFunc.ForJobs(config.NameTemplate, config.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []model.BackupJob
+	for _, schema := range schemas {
+		timeout := config.SchemaTimeout
+
+		job := model.BackupJob{
+			Database:         database,
+			OutputRoot:       config.OutputRoot,
+			NameTemplate:     template.String(),
+			Schema:           schema,
+			Format:           config.Format,
+			Jobs:             config.Jobs,
+			Content:          config.Content,
+			Compression:      config.Compression,
+			CompressionLevel: config.CompressionLevel,
+			Encryption:       config.Encryption,
+			PgBinDir:         config.PgBinDir,
+		}
+
+		for _, target := range config.Targets {
+			matched, err := path.Match(target.Schema, schema)
+			if err != nil {
+				return nil, fmt.Errorf("invalid target schema pattern %q: %v", target.Schema, err)
+			}
+			if !matched {
+				continue
+			}
+			if target.Format != "" {
+				job.Format = target.Format
+			}
+			if target.Jobs != 0 {
+				job.Jobs = target.Jobs
+			}
+			if target.Content != "" {
+				job.Content = target.Content
+			}
+			if target.Compression != "" {
+				job.Compression = target.Compression
+				job.CompressionLevel = target.CompressionLevel
+			}
+			if target.Timeout != "" {
+				timeout = target.Timeout
+			}
+			if target.PgBinDir != "" {
+				job.PgBinDir = target.PgBinDir
+			}
+			job.Priority = target.Priority
+			job.TableRules = target.Tables
+			break
+		}
+
+		if timeout != "" {
+			parsed, err := time.ParseDuration(timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout %q for schema %s: %v", timeout, schema, err)
+			}
+			job.Timeout = parsed
+		}
+
+		if err := validateJob(job); err != nil {
+			return nil, fmt.Errorf("invalid settings for schema %s: %v", schema, err)
+		}
+
+		if job.Content != model.ContentSplitSections {
+			jobs = append(jobs, job)
+			continue
+		}
+		for _, section := range []string{model.SectionPreData, model.SectionData, model.SectionPostData} {
+			job.Section = section
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// GlobalsJob builds the job dumping the cluster globals. Globals are always
+// a plain SQL script, compressed and encrypted like the schema dumps.
+func GlobalsJob(config *model.BackupConfig) (model.BackupJob, error) {
+	job := model.BackupJob{
+		OutputRoot:       config.OutputRoot,
+		NameTemplate:     namingFunc.GlobalsTemplate,
+		Format:           model.FormatPlain,
+		Content:          model.ContentFull,
+		Compression:      config.Compression,
+		CompressionLevel: config.CompressionLevel,
+		Encryption:       config.Encryption,
+		PgBinDir:         config.PgBinDir,
+	}
+
+	if config.SchemaTimeout != "" {
+		parsed, err := time.ParseDuration(config.SchemaTimeout)
+		if err != nil {
+			return job, fmt.Errorf("invalid timeout %q for globals: %v", config.SchemaTimeout, err)
+		}
+		job.Timeout = parsed
+	}
+
+	if err := validateJob(job); err != nil {
+		return job, fmt.Errorf("invalid settings for globals: %v", err)
+	}
+	return job, nil
+}
+
+func validateJob(job model.BackupJob) error {
+	switch job.Format {
+	case model.FormatPlain, model.FormatCustom, model.FormatDirectory, model.FormatTar:
+	default:
+		return fmt.Errorf("unknown format %q", job.Format)
+	}
+
+	switch job.Content {
+	case model.ContentFull, model.ContentSchemaOnly, model.ContentDataOnly, model.ContentSplitSections:
+	default:
+		return fmt.Errorf("unknown content mode %q", job.Content)
+	}
+
+	if job.Jobs < 0 {
+		return fmt.Errorf("jobs must not be negative")
+	}
+	if job.Jobs > 1 && job.Format != model.FormatDirectory {
+		return fmt.Errorf("parallel jobs are only supported by the directory format")
+	}
+
+	for _, patterns := range [][]string{job.TableRules.Include, job.TableRules.Exclude, job.TableRules.ExcludeData} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	if err := compressFunc.Validate(job.Compression, job.CompressionLevel); err != nil {
+		return err
+	}
+	if job.Compression != model.CompressionNone && job.Format == model.FormatDirectory {
+		return fmt.Errorf("compression cannot be applied to the directory format")
+	}
+	if encryptFunc.Enabled(job.Encryption) && job.Format == model.FormatDirectory {
+		return fmt.Errorf("encryption cannot be applied to the directory format")
+	}
+	return nil
+}
+
+func loadConfigFile(fileName string, config *model.BackupConfig) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	if err = json.Unmarshal(content, config); err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", fileName, err)
+	}
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyString overrides a setting with the environment variable, then the flag value
+func applyString(setting *string, envName, flagValue string) {
+	if value := os.Getenv(envName); value != "" {
+		*setting = value
+	}
+	if flagValue != "" {
+		*setting = flagValue
+	}
+}
+
+// applyInt overrides a setting with the environment variable, then the flag value
+func applyInt(setting *int, envName string, flagValue int) error {
+	if value := os.Getenv(envName); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %v", envName, value, err)
+		}
+		*setting = parsed
+	}
+	if flagValue != 0 {
+		*setting = flagValue
+	}
+	return nil
+}
+
+// applyBool overrides a setting with the environment variable, then the flag value
+func applyBool(setting *bool, envName string, flagValue bool) error {
+	if value := os.Getenv(envName); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %v", envName, value, err)
+		}
+		*setting = parsed
+	}
+	if flagValue {
+		*setting = flagValue
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package checkPsqlLatestVersion
 
 import (
 	"backup/model"
+	"backup/versionFunc"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,54 +10,28 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"regexp"
-	"strings"
 )
 
-// GetAndParseServerVersion gets and parses the PostgreSQL server version
-func GetAndParseServerVersion(db *sql.DB) (*model.PostgresqlVersion, error) {
-	serverPsqlVersion, err := checkDatabaseVersion(db)
-	if err != nil {
-		return nil, fmt.Errorf("error checking database version: %v", err)
-	}
-
-	parsedVersion, err := parsePostgresqlVersion(*serverPsqlVersion)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing version: %v", err)
+// GetAndParseServerVersion gets the PostgreSQL server version from
+// server_version_num, adding the beta or rc stage server_version shows
+func GetAndParseServerVersion(db *sql.DB) (versionFunc.Version, error) {
+	var versionNum, versionString string
+	if err := db.QueryRow("SELECT current_setting('server_version_num'), current_setting('server_version')").Scan(&versionNum, &versionString); err != nil {
+		return versionFunc.Version{}, fmt.Errorf("error querying database version: %v", err)
 	}
 
-	return parsedVersion, nil
-}
-
-func checkDatabaseVersion(db *sql.DB) (*string, error) {
-	var version string
-	err := db.QueryRow("SHOW server_version").Scan(&version)
+	version, err := versionFunc.FromNum(versionNum)
 	if err != nil {
-		return nil, fmt.Errorf("error querying database version: %v", err)
+		return versionFunc.Version{}, fmt.Errorf("error parsing version: %v", err)
 	}
-	return &version, nil
-}
 
-func parsePostgresqlVersion(versionString string) (*model.PostgresqlVersion, error) {
-	re := regexp.MustCompile(`(\d+\.\d+)`)
-	matches := re.FindStringSubmatch(versionString)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid version string: %s", versionString)
+	if named, err := versionFunc.Parse(versionString); err == nil && named.Num == version.Num {
+		version = named
 	}
-
-	latestVersionWithMinor := matches[1]
-	versionParts := strings.Split(latestVersionWithMinor, ".")
-	versionMinor := versionParts[0]
-	patchVersion := versionParts[1]
-
-	return &model.PostgresqlVersion{
-		LatestVersionWithMinor: &latestVersionWithMinor,
-		VersionMinor:           &versionMinor,
-		PatchVersion:           &patchVersion,
-	}, nil
+	return version, nil
 }
 
-func CheckCurrentPostgresqlLatestVersion() (*model.PostgresqlVersion, error) {
+func CheckCurrentPostgresqlLatestVersion() (*model.PostgresqlRelease, error) {
 
 	url := "https://www.postgresql.org/versions.json"
 	method := "GET"
@@ -92,31 +67,25 @@ func CheckCurrentPostgresqlLatestVersion() (*model.PostgresqlVersion, error) {
 		return nil, err
 	}
 
-	var latestVersionWithMinor string
-	var versionMinor string
-	var psqlUrl *string
-
 	for i := len(versions) - 1; i >= 0; i-- {
 		if versions[i].Current == true {
-			latestVersionWithMinor = versions[i].Major + "." + versions[i].LatestMinor
-			versionMinor = versions[i].Major
-			psqlUrl, err = getHrefLatestWindowsVersion(latestVersionWithMinor)
+			latestVersion, err := versionFunc.Parse(versions[i].Major + "." + versions[i].LatestMinor)
+			if err != nil {
+				err = fmt.Errorf("error parsing latest version: %v", err)
+				log.Println(err)
+				return nil, err
+			}
+			psqlUrl, err := getHrefLatestWindowsVersion(latestVersion.String())
 			if err != nil {
 				err = fmt.Errorf("error getting href latest windows version: %v", err)
 				log.Println(err)
 				return nil, err
 			}
-			break
+			return &model.PostgresqlRelease{Version: latestVersion, InstallerURL: *psqlUrl}, nil
 		}
 	}
 
-	postgresqlVersion := model.PostgresqlVersion{
-		LatestVersionWithMinor: &latestVersionWithMinor,
-		VersionMinor:           &versionMinor,
-		PsqlUrl:                psqlUrl,
-	}
-
-	return &postgresqlVersion, nil
+	return nil, fmt.Errorf("no current PostgreSQL version found in %s", url)
 }
 
 func getHrefLatestWindowsVersion(pgLatestVersion string) (*string, error) {
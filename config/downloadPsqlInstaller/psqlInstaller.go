@@ -10,9 +10,9 @@ import (
 	"path/filepath"
 )
 
-func DownloadPsqlInstaller(path *string, postgresqlLatestVersion *model.PostgresqlVersion) error {
+func DownloadPsqlInstaller(path *string, postgresqlLatestVersion *model.PostgresqlRelease) error {
 	//// Step 1: Tải xuống file .exe
-	resp, err := http.Get(*postgresqlLatestVersion.PsqlUrl)
+	resp, err := http.Get(postgresqlLatestVersion.InstallerURL)
 	if err != nil {
 		err = fmt.Errorf("error downloading psql: %v", err)
 		log.Println(err)
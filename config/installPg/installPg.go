@@ -5,31 +5,37 @@ import (
 	"backup/config/downloadPsqlInstaller"
 	"backup/config/getCurrentFolderPath"
 	"backup/model"
+	"backup/platformFunc"
+	"backup/versionFunc"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"syscall"
 )
 
 // installLatestPostgreSQL installs the latest PostgreSQL version
-func InstallLatestPostgreSQL() (string, error) {
+func InstallLatestPostgreSQL() (versionFunc.Version, error) {
 	postgresqlLatestVersion, err := checkPsqlLatestVersion.CheckCurrentPostgresqlLatestVersion()
 	if err != nil {
-		return "", fmt.Errorf("error checking latest PostgreSQL version: %v", err)
+		return versionFunc.Version{}, fmt.Errorf("error checking latest PostgreSQL version: %v", err)
 	}
 
 	if err = installIfNotExist(postgresqlLatestVersion); err != nil {
-		return "", fmt.Errorf("installation failed: %v", err)
+		return versionFunc.Version{}, fmt.Errorf("installation failed: %v", err)
 	}
 
-	log.Printf("PostgreSQL %s installed", *postgresqlLatestVersion.LatestVersionWithMinor)
-	return *postgresqlLatestVersion.VersionMinor, nil
+	log.Printf("PostgreSQL %s installed", postgresqlLatestVersion.Version)
+	return postgresqlLatestVersion.Version, nil
 }
 
-func installIfNotExist(postgresqlLatestVersion *model.PostgresqlVersion) error {
-	mess := fmt.Sprintf("Đang cài đặt phiên bản mới nhất: %s", *postgresqlLatestVersion.LatestVersionWithMinor)
+func installIfNotExist(postgresqlLatestVersion *model.PostgresqlRelease) error {
+	// Find out whether this platform can install at all before downloading
+	installDir, err := platformFunc.Current.InstallDir(postgresqlLatestVersion.Version.MajorString())
+	if err != nil {
+		return err
+	}
+
+	mess := fmt.Sprintf("Đang cài đặt phiên bản mới nhất: %s", postgresqlLatestVersion.Version)
 	log.Println(mess)
 
 	currentPath, err := getCurrentFolderPath.GetCurrentFolderPath()
@@ -50,9 +56,8 @@ func installIfNotExist(postgresqlLatestVersion *model.PostgresqlVersion) error {
 	}
 
 	exeFile := filepath.Join(currentPath, model.InstallersDir, "psql_installer.exe")
-	installDir := "C:\\Program Files\\PostgreSQL\\" + *postgresqlLatestVersion.VersionMinor
 
-	err = runInstallerWithBatch(exeFile, installDir)
+	err = platformFunc.Current.RunInstaller(exeFile, installDir)
 	if err != nil {
 		log.Fatalf("Error running installer: %v", err)
 	}
@@ -61,35 +66,3 @@ func installIfNotExist(postgresqlLatestVersion *model.PostgresqlVersion) error {
 	log.Println(mess)
 	return nil
 }
-
-func runInstallerWithBatch(exeFile, installDir string) error {
-	batchFile := "install.bat"
-	content := fmt.Sprintf(`@echo off 
-"%s" --mode unattended --prefix "%s"
-`, exeFile, installDir)
-
-	err := os.WriteFile(batchFile, []byte(content), 0644)
-	if err != nil {
-		return err
-	}
-
-	defer os.Remove(batchFile)
-
-	cmd := exec.Command("cmd", "/c", batchFile)
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	if err := os.Remove(exeFile); err != nil {
-		return fmt.Errorf("error removing exe file: %v", err)
-	}
-
-	mess := fmt.Sprintln("Installer removed after installation.")
-	log.Println(mess)
-
-	return nil
-}
@@ -0,0 +1,203 @@
+package locateTools
+
+import (
+	"backup/config/installPg"
+	"backup/model"
+	"backup/platformFunc"
+	"backup/versionFunc"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Installation is one set of PostgreSQL client tools found on this machine
+type Installation struct {
+	// Version is the version pg_dump reports
+	Version versionFunc.Version
+	// BinDir is the absolute directory holding the tools
+	BinDir string
+	// Tools are the probed tools, set by ForServer
+	Tools []model.ClientTool
+}
+
+// Tool returns the absolute path of a client tool of the installation
+func (i Installation) Tool(tool string) string {
+	return platformFunc.ToolPath(i.BinDir, tool)
+}
+
+var versionPattern = regexp.MustCompile(`\(PostgreSQL\) (\S+)`)
+
+// Scan returns every installation found in the platform's standard layouts
+// and on PATH, ordered by major version
+func Scan() []Installation {
+	var dirs []string
+	for _, pattern := range platformFunc.Current.ToolDirs() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, matches...)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	var installations []Installation
+	seenTools := map[string]bool{}
+	seenVersions := map[string]bool{}
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		toolPath := platformFunc.ToolPath(dir, "pg_dump")
+		resolved, err := filepath.EvalSymlinks(toolPath)
+		if err != nil || seenTools[resolved] {
+			continue
+		}
+		seenTools[resolved] = true
+
+		version, err := toolVersion(toolPath)
+		if err != nil {
+			log.Printf("Skipping %s: %v", toolPath, err)
+			continue
+		}
+
+		// Wrappers on PATH usually run one of the installations found before
+		if seenVersions[version.String()] {
+			continue
+		}
+		seenVersions[version.String()] = true
+		installations = append(installations, Installation{Version: version, BinDir: dir})
+	}
+
+	sort.SliceStable(installations, func(a, b int) bool {
+		return installations[a].Version.Major() < installations[b].Version.Major()
+	})
+	return installations
+}
+
+// toolVersion runs a tool with --version and parses the version it reports
+func toolVersion(toolPath string) (versionFunc.Version, error) {
+	output, err := exec.Command(toolPath, "--version").Output()
+	if err != nil {
+		return versionFunc.Version{}, fmt.Errorf("error running --version: %v", err)
+	}
+	matches := versionPattern.FindStringSubmatch(string(output))
+	if matches == nil {
+		return versionFunc.Version{}, fmt.Errorf("could not extract version from %q", strings.TrimSpace(string(output)))
+	}
+	return versionFunc.Parse(matches[1])
+}
+
+// Choose returns the lowest installation whose major version is at least
+// the server's, since pg_dump cannot dump newer servers and the closest
+// version gives the most compatible output
+func Choose(installations []Installation, server versionFunc.Version) (Installation, bool) {
+	for _, installation := range installations {
+		if installation.Version.CanDump(server) {
+			return installation, true
+		}
+	}
+	return Installation{}, false
+}
+
+// Newest returns the installation with the highest major version, which
+// can read archives of every older version
+func Newest() (Installation, error) {
+	installations := Scan()
+	if len(installations) == 0 {
+		return Installation{}, fmt.Errorf("no PostgreSQL client tools found")
+	}
+	return installations[len(installations)-1], nil
+}
+
+// FromDir returns the probed installation in dir without searching for or
+// installing tools, for when the user names the directory to use
+func FromDir(dir string, server versionFunc.Version) (Installation, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Installation{}, fmt.Errorf("error resolving client tools directory: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return Installation{}, fmt.Errorf("client tools directory %s does not exist", dir)
+	}
+
+	version, err := toolVersion(platformFunc.ToolPath(dir, "pg_dump"))
+	if err != nil {
+		return Installation{}, fmt.Errorf("pg_dump in %s is not usable: %v", dir, err)
+	}
+
+	installation := Installation{Version: version, BinDir: dir}
+	if installation.Tools, err = Probe(installation, server); err != nil {
+		return Installation{}, err
+	}
+	return installation, nil
+}
+
+// probedTools are the tools a run needs. pg_dump and pg_dumpall must be
+// at least as new as the server, pg_restore and psql only have to work.
+var probedTools = []struct {
+	name        string
+	checkServer bool
+}{
+	{"pg_dump", true},
+	{"pg_dumpall", true},
+	{"pg_restore", false},
+	{"psql", false},
+}
+
+// Probe runs every tool of the installation with --version, so a missing or
+// broken tool, or one older than the server, fails before any dump starts
+func Probe(installation Installation, server versionFunc.Version) ([]model.ClientTool, error) {
+	var tools []model.ClientTool
+	for _, probed := range probedTools {
+		toolPath := installation.Tool(probed.name)
+		version, err := toolVersion(toolPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s in %s is not usable: %v", probed.name, installation.BinDir, err)
+		}
+		if probed.checkServer && !version.CanDump(server) {
+			return nil, fmt.Errorf("server is PostgreSQL %s but %s is %s, install the client tools for PostgreSQL %s or later", server, toolPath, version, server.MajorString())
+		}
+		log.Printf("Probed %s %s at %s", probed.name, version, toolPath)
+		tools = append(tools, model.ClientTool{Name: probed.name, Path: toolPath, Version: version.String(), Major: version.MajorString()})
+	}
+	return tools, nil
+}
+
+// ForServer returns the probed installation to use with the server,
+// installing the latest version when none is suitable
+func ForServer(server versionFunc.Version) (Installation, error) {
+	installations := Scan()
+	for _, installation := range installations {
+		log.Printf("Found PostgreSQL %s client tools in %s", installation.Version, installation.BinDir)
+	}
+
+	installation, ok := Choose(installations, server)
+	if !ok {
+		newest := "none"
+		if len(installations) > 0 {
+			last := installations[len(installations)-1]
+			newest = last.Version.String() + " in " + last.BinDir
+		}
+		log.Printf("Server is PostgreSQL %s but the newest pg_dump found is %s", server, newest)
+
+		if _, err := installPg.InstallLatestPostgreSQL(); err != nil {
+			return Installation{}, fmt.Errorf("server is PostgreSQL %s but the newest pg_dump found is %s: %v", server, newest, err)
+		}
+		if installation, ok = Choose(Scan(), server); !ok {
+			return Installation{}, fmt.Errorf("no pg_dump for PostgreSQL %s or later found after installing", server.MajorString())
+		}
+	}
+
+	tools, err := Probe(installation, server)
+	if err != nil {
+		return Installation{}, err
+	}
+	installation.Tools = tools
+	return installation, nil
+}
@@ -0,0 +1,187 @@
+package schemaDiscovery
+
+import (
+	"backup/model"
+	"database/sql"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// systemSchemas are never backed up on their own, whatever the patterns say
+var systemSchemas = []string{"pg_*", "information_schema"}
+
+// DiscoverSchemas resolves the configured schema patterns against the schemas
+// that exist in the connected database
+func DiscoverSchemas(db *sql.DB, patterns []string) ([]string, error) {
+	includes, excludes, err := splitPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := listSchemas(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []string
+	for _, schema := range existing {
+		if matchAny(systemSchemas, schema) || matchAny(excludes, schema) {
+			continue
+		}
+		if matchAny(includes, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+
+	// Warn about schema names that were asked for explicitly but do not exist
+	for _, include := range includes {
+		if isLiteral(include) && !slices.Contains(existing, include) {
+			log.Printf("Schema %s not found in database, skipping", include)
+		}
+	}
+
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("no schemas match %s", strings.Join(patterns, ","))
+	}
+
+	return schemas, nil
+}
+
+// DiscoverDatabases lists the databases on the server that accept connections,
+// skipping templates and databases matching one of the exclude patterns
+func DiscoverDatabases(db *sql.DB, excludes []string) ([]string, error) {
+	for _, pattern := range excludes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid database pattern %q: %v", pattern, err)
+		}
+	}
+
+	rows, err := db.Query("SELECT datname FROM pg_database WHERE NOT datistemplate AND datallowconn ORDER BY datname")
+	if err != nil {
+		return nil, fmt.Errorf("error querying databases: %v", err)
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var database string
+		if err = rows.Scan(&database); err != nil {
+			return nil, fmt.Errorf("error reading database name: %v", err)
+		}
+		if !matchAny(excludes, database) {
+			databases = append(databases, database)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading databases: %v", err)
+	}
+
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("no databases left after excluding %s", strings.Join(excludes, ","))
+	}
+	return databases, nil
+}
+
+// DiscoverTables resolves the table rules of a schema against its tables. It
+// returns the tables that are dumped, those among them dumped without data and
+// the tables left out by the exclude rules.
+func DiscoverTables(db *sql.DB, schema string, rules model.TableRules) ([]string, []string, []string, error) {
+	rows, err := db.Query(`SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm', 'f', 'S') ORDER BY c.relname`, schema)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error querying tables of %s: %v", schema, err)
+	}
+	defer rows.Close()
+
+	var tables, withoutData, excluded []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading table name: %v", err)
+		}
+		if len(rules.Include) > 0 && !matchAny(rules.Include, table) {
+			continue
+		}
+		if matchAny(rules.Exclude, table) {
+			excluded = append(excluded, table)
+			continue
+		}
+		tables = append(tables, table)
+		if matchAny(rules.ExcludeData, table) {
+			withoutData = append(withoutData, table)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading tables of %s: %v", schema, err)
+	}
+
+	if len(rules.Include) > 0 && len(tables) == 0 {
+		return nil, nil, nil, fmt.Errorf("no tables in %s match %s", schema, strings.Join(rules.Include, ","))
+	}
+	return tables, withoutData, excluded, nil
+}
+
+func listSchemas(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT schema_name FROM information_schema.schemata")
+	if err != nil {
+		return nil, fmt.Errorf("error querying schemas: %v", err)
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var schema string
+		if err = rows.Scan(&schema); err != nil {
+			return nil, fmt.Errorf("error reading schema name: %v", err)
+		}
+		schemas = append(schemas, schema)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading schemas: %v", err)
+	}
+
+	sort.Strings(schemas)
+	return schemas, nil
+}
+
+func splitPatterns(patterns []string) ([]string, []string, error) {
+	var includes, excludes []string
+	for _, pattern := range patterns {
+		target := &includes
+		if strings.HasPrefix(pattern, "!") {
+			target = &excludes
+			pattern = strings.TrimPrefix(pattern, "!")
+		}
+		if strings.EqualFold(pattern, model.AllSchemas) {
+			pattern = "*"
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid schema pattern %q: %v", pattern, err)
+		}
+		*target = append(*target, pattern)
+	}
+
+	// Only exclude patterns given means "everything except these"
+	if len(includes) == 0 {
+		includes = []string{"*"}
+	}
+
+	return includes, excludes, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isLiteral(pattern string) bool {
+	return !strings.ContainsAny(pattern, `*?[\`)
+}
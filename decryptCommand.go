@@ -0,0 +1,64 @@
+package main
+
+import (
+	"backup/config/backupConfig"
+	"backup/encryptFunc"
+	"backup/model"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// runDecrypt writes a decrypted copy of an encrypted artifact next to it
+func runDecrypt(args []string) {
+	flags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	identityFile := flags.String("identity", "", "age identity file holding the private key")
+	output := flags.String("out", "", "output file, defaults to the input name without .age")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: decrypt [--identity key.txt] [--out file] <artifact%s>", encryptFunc.Extension)
+	}
+	input := flags.Arg(0)
+
+	if *output == "" {
+		if !strings.HasSuffix(input, encryptFunc.Extension) {
+			log.Fatalf("Artifact %s has no %s extension, use --out to name the output", input, encryptFunc.Extension)
+		}
+		*output = strings.TrimSuffix(input, encryptFunc.Extension)
+	}
+
+	if err := decryptFile(input, *output, backupConfig.ScanDecryptionConfig(*identityFile)); err != nil {
+		log.Fatalf("Decryption failed: %v", err)
+	}
+
+	log.Printf("Decrypted %s to %s", input, *output)
+}
+
+func decryptFile(input, output string, config model.DecryptionConfig) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("error opening artifact: %v", err)
+	}
+	defer in.Close()
+
+	decrypted, err := encryptFunc.NewReader(in, config)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, decrypted); err != nil {
+		return fmt.Errorf("error writing decrypted data: %v", err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,117 @@
+package encryptFunc
+
+import (
+	"backup/model"
+	"filippo.io/age"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Extension is appended to the name of every encrypted artifact
+const Extension = ".age"
+
+// Enabled reports whether the settings ask for encrypted artifacts
+func Enabled(config model.EncryptionConfig) bool {
+	return config.Passphrase != "" || len(config.Recipients) > 0
+}
+
+// Validate checks that the settings can be turned into age recipients
+func Validate(config model.EncryptionConfig) error {
+	_, err := recipients(config)
+	return err
+}
+
+// NewWriter wraps w so everything written to it is encrypted for the
+// configured recipients. Closing the returned writer does not close w.
+func NewWriter(w io.Writer, config model.EncryptionConfig) (io.WriteCloser, error) {
+	if !Enabled(config) {
+		return nopCloser{w}, nil
+	}
+
+	ageRecipients, err := recipients(config)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptor, err := age.Encrypt(w, ageRecipients...)
+	if err != nil {
+		return nil, fmt.Errorf("error starting encryption: %v", err)
+	}
+	return encryptor, nil
+}
+
+// NewReader returns a reader that decrypts r with the identity file or the
+// passphrase from the decryption settings
+func NewReader(r io.Reader, config model.DecryptionConfig) (io.Reader, error) {
+	identities, err := identities(config)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting: %v", err)
+	}
+	return decrypted, nil
+}
+
+func recipients(config model.EncryptionConfig) ([]age.Recipient, error) {
+	if config.Passphrase != "" {
+		// age only allows a passphrase as the single recipient of a file
+		if len(config.Recipients) > 0 {
+			return nil, fmt.Errorf("encryption passphrase and recipients cannot be used together")
+		}
+		recipient, err := age.NewScryptRecipient(config.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("error creating passphrase recipient: %v", err)
+		}
+		return []age.Recipient{recipient}, nil
+	}
+
+	ageRecipients, err := age.ParseRecipients(strings.NewReader(strings.Join(config.Recipients, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing encryption recipients: %v", err)
+	}
+	return ageRecipients, nil
+}
+
+func identities(config model.DecryptionConfig) ([]age.Identity, error) {
+	var ageIdentities []age.Identity
+
+	if config.IdentityFile != "" {
+		file, err := os.Open(config.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("error opening identity file: %v", err)
+		}
+		defer file.Close()
+
+		parsed, err := age.ParseIdentities(file)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing identity file %s: %v", config.IdentityFile, err)
+		}
+		ageIdentities = append(ageIdentities, parsed...)
+	}
+
+	if config.Passphrase != "" {
+		identity, err := age.NewScryptIdentity(config.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("error creating passphrase identity: %v", err)
+		}
+		ageIdentities = append(ageIdentities, identity)
+	}
+
+	if len(ageIdentities) == 0 {
+		return nil, fmt.Errorf("artifact is encrypted, set BACKUP_DECRYPT_IDENTITY_FILE or BACKUP_ENCRYPT_PASSPHRASE to decrypt it")
+	}
+	return ageIdentities, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -3,14 +3,17 @@ module backup
 go 1.23.1
 
 require (
+	filippo.io/age v1.2.1
 	github.com/PuerkitoBio/goquery v1.10.0
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.18.0
 	github.com/lib/pq v1.10.9
+	golang.org/x/sys v0.28.0
 	golang.org/x/term v0.27.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
+	golang.org/x/crypto v0.30.0 // indirect
 	golang.org/x/net v0.32.0 // indirect
-	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"backup/catalogFunc"
+	"backup/config/backupConfig"
+	"backup/model"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// runList prints the backups found in the backups directory with their catalog IDs
+func runList(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	scanStorage := backupConfig.StorageFlags(flags)
+	_ = flags.Parse(args)
+
+	storage, err := scanStorage()
+	if err != nil {
+		log.Fatalf("Error reading backup configuration: %v", err)
+	}
+
+	entries, err := catalogFunc.List(storage)
+	if err != nil {
+		log.Fatalf("Error listing backups: %v", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tRUN\tSCHEMA\tTIME\tFORMAT\tCOMPRESSION\tENCRYPTED")
+	for _, entry := range entries {
+		run := entry.RunID
+		if run == "" {
+			run = "-"
+		}
+		schema := entry.Schema
+		if entry.Kind == model.KindGlobals {
+			schema = "(globals)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", entry.ID, run, schema,
+			entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Format, entry.Compression, entry.Encrypted)
+	}
+	writer.Flush()
+}
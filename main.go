@@ -2,28 +2,96 @@ package main
 
 import (
 	"backup/backupFunc"
+	"backup/config/backupConfig"
 	"backup/config/checkPsqlLatestVersion"
-	"backup/config/checkPsqlVersionExistOnWindows"
 	"backup/config/dbconfig"
+	"backup/config/locateTools"
+	"backup/manifestFunc"
+	"backup/model"
+	"backup/platformFunc"
+	"backup/retentionFunc"
+	"backup/spaceFunc"
+	"backup/versionFunc"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 )
 
 func main() {
 	// Initialize logging and setup
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// The first argument selects the command, backup is the default
+	command, args := "backup", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "backup":
+		runBackup(args)
+	case "restore":
+		runRestore(args)
+	case "list":
+		runList(args)
+	case "prune":
+		runPrune(args)
+	case "verify":
+		runVerify(args)
+	case "decrypt":
+		runDecrypt(args)
+	default:
+		log.Fatalf("Unknown command %q, expected backup, restore, list, prune, verify or decrypt", command)
+	}
+}
+
+// clientTools returns the client tools that can work with the connected
+// server and the server version they were chosen for. The tools in pgBinDir
+// are used when it is set, otherwise installed tools are searched for and
+// the latest version is installed if none is suitable.
+func clientTools(db *sql.DB, pgBinDir string) (locateTools.Installation, versionFunc.Version, error) {
+	// Get server PostgreSQL version
+	serverVersion, err := checkPsqlLatestVersion.GetAndParseServerVersion(db)
+	if err != nil {
+		return locateTools.Installation{}, versionFunc.Version{}, fmt.Errorf("error processing database version: %v", err)
+	}
+
+	var tools locateTools.Installation
+	if pgBinDir != "" {
+		tools, err = locateTools.FromDir(pgBinDir, serverVersion)
+	} else {
+		tools, err = locateTools.ForServer(serverVersion)
+	}
+	if err != nil {
+		return locateTools.Installation{}, versionFunc.Version{}, fmt.Errorf("error finding PostgreSQL client tools: %v", err)
+	}
+	log.Printf("Using PostgreSQL %s client tools in %s", tools.Version, tools.BinDir)
+	return tools, serverVersion, nil
+}
+
+func runBackup(args []string) {
 	elevated := false
-	for _, arg := range os.Args {
+	for _, arg := range args {
 		if arg == "--elevated" {
 			elevated = true
 			break
 		}
 	}
 
+	// Get backup settings
+	config, err := backupConfig.ScanBackupConfig(args)
+	if err != nil {
+		log.Fatalf("Error reading backup configuration: %v", err)
+	}
+
 	// Get database credentials
 	creds, err := dbconfig.ScanCredsInformation()
 	if err != nil {
@@ -38,21 +106,75 @@ func main() {
 	log.Println("Database connection successful")
 	defer db.Close()
 
-	// Get server PostgreSQL version
-	serverVersion, err := checkPsqlLatestVersion.GetAndParseServerVersion(db)
+	// Resolve which databases and schemas to back up
+	jobs, err := planBackupJobs(config, creds, db)
 	if err != nil {
-		log.Fatalf("Error processing database version: %v", err)
+		log.Fatal(err)
+	}
+
+	var globalsJob model.BackupJob
+	if config.Globals {
+		if globalsJob, err = backupConfig.GlobalsJob(config); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if config.DryRun {
+		if config.Globals {
+			log.Printf("Dry run: would back up globals with pg_dumpall (compression %s) into %s", globalsJob.Compression, filepath.Join(globalsJob.OutputRoot, globalsJob.NameTemplate))
+		}
+		for _, job := range jobs {
+			log.Printf("Dry run: would back up %s as %s %s (compression %s) into %s", backupFunc.JobName(job), job.Content, job.Format, job.Compression, filepath.Join(job.OutputRoot, job.NameTemplate))
+			log.Printf("Dry run:   estimated size: %s", spaceFunc.FormatBytes(job.EstimatedBytes))
+			if job.PgBinDir != "" {
+				log.Printf("Dry run:   client tools: %s", job.PgBinDir)
+			}
+			if job.Tables != nil {
+				log.Printf("Dry run:   tables: %s", strings.Join(job.Tables, ", "))
+			}
+			if job.TablesWithoutData != nil {
+				log.Printf("Dry run:   without data: %s", strings.Join(job.TablesWithoutData, ", "))
+			}
+			if job.ExcludedTables != nil {
+				log.Printf("Dry run:   excluded: %s", strings.Join(job.ExcludedTables, ", "))
+			}
+		}
+		if err = checkSpace(config, jobs); err != nil {
+			log.Printf("Dry run: %v", err)
+		}
+		if config.PruneAfterBackup {
+			if err = retentionFunc.Prune(config.StorageConfig, config.Retention, true); err != nil {
+				log.Fatalf("Error pruning backups: %v", err)
+			}
+		}
+		return
+	}
+
+	// Refuse to start a backup that would fill the disk halfway through
+	if !config.SkipSpaceCheck {
+		if err = checkSpace(config, jobs); err != nil {
+			log.Fatal(err)
+		}
 	}
-	connectionDBVersion := *serverVersion.VersionMinor + "." + *serverVersion.PatchVersion
 
 	// Determine which PostgreSQL version to use for backup tools
-	addPathVersion, err := checkPsqlVersionExistOnWindows.HandlePostgreSQLInstallation(connectionDBVersion)
+	tools, serverVersion, err := clientTools(db, config.PgBinDir)
 	if err != nil {
-		log.Fatalf("Error handling PostgreSQL installation: %v", err)
+		log.Fatal(err)
 	}
 
-	if !elevated {
-		needsRelaunch, err := addPath(addPathVersion)
+	// Targets with their own tools directory are checked before anything is dumped
+	targetTools, err := probeTargetTools(config, jobs, serverVersion)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Tools given explicitly are run by path, PATH is only set up for detected ones
+	switch {
+	case config.PgBinDir != "":
+		log.Printf("Client tools directory given, PATH is left unchanged")
+	case !elevated:
+		needsRelaunch, err := addPath(tools.BinDir)
 		if err != nil {
 			log.Fatalf("Error adding PostgreSQL path to system Path: %v", err)
 		}
@@ -62,35 +184,110 @@ func main() {
 			log.Println("Backup successful")
 			return
 		}
-	} else {
-		customPath := "C:\\Program Files\\PostgreSQL\\" + addPathVersion + "\\bin"
-		err := addToSystemPath(customPath)
+	default:
+		customPath := tools.BinDir
+		err := platformFunc.Current.AddToPath(customPath)
 		if err != nil {
 			log.Fatalf("Error adding custom path to system PATH: %v", err)
 		}
 		log.Printf("Custom path added to system PATH: %s\n", customPath)
 	}
 
+	manifest, err := manifestFunc.NewManifest(creds, serverVersion.String(), append(tools.Tools, targetTools...))
+	if err != nil {
+		log.Fatalf("Error starting run manifest: %v", err)
+	}
+	manifest.Cluster = config.Cluster
+	log.Printf("Starting backup run %s", manifest.RunID)
+
+	// All dumps of a database read one exported snapshot, kept open until they finish
+	snapshots := exportSnapshots(creds, jobs)
+
+	// Row counts let verify compare a test restore with the source
+	var rowCounts map[string]map[string]int64
+	if config.RecordRowCounts {
+		if rowCounts, err = recordRowCounts(creds, db, jobs, snapshots); err != nil {
+			log.Fatalf("Error recording row counts: %v", err)
+		}
+	}
+
+	// Ctrl-C or a termination request cancels the run and kills running pg_dump processes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Globals go first, restores replay them before any schema
+	var results []model.SchemaResult
+	var globalsErr error
+	if config.Globals {
+		var result model.SchemaResult
+		result, globalsErr = backupFunc.PerformGlobalsBackup(ctx, creds, tools.BinDir, globalsJob, config.NoRolePasswords, manifest)
+		results = append(results, result)
+	}
+
 	// Perform backups concurrently
-	if err = backupFunc.PerformDatabaseBackups(creds, addPathVersion); err != nil {
-		log.Fatalf("Backup failed: %v", err)
+	schemaResults, backupErr := backupFunc.PerformDatabaseBackups(ctx, creds, tools.BinDir, jobs, manifest, config)
+	closeSnapshots(snapshots)
+	results = append(results, schemaResults...)
+	backupErr = errors.Join(globalsErr, backupErr)
+	for _, result := range results {
+		name := result.Database + "." + result.Schema
+		switch result.Kind {
+		case model.KindGlobals:
+			name = "globals"
+		case model.KindDump:
+		default:
+			name += " (" + result.Kind + ")"
+		}
+		if result.Status == model.StatusSucceeded {
+			log.Printf("%s: %s, %d bytes in %.1fs -> %s", name, result.Status, result.Bytes, result.DurationSeconds, result.ArtifactPath)
+		} else {
+			log.Printf("%s: %s after %.1fs: %s", name, result.Status, result.DurationSeconds, result.Error)
+		}
+	}
+	for i, artifact := range manifest.Artifacts {
+		// Test restores check counts against full dumps, or the data section of split dumps
+		if artifact.Kind != model.KindDump && artifact.Kind != model.KindDataSection {
+			continue
+		}
+		manifest.Artifacts[i].RowCounts = rowCounts[artifact.Database+"."+artifact.Schema]
+	}
+
+	// Record whatever was produced, even when some schemas failed
+	manifest.Results = results
+	manifest.FinishedAt = time.Now()
+	if len(manifest.Artifacts) > 0 {
+		manifestPath, err := manifestFunc.Write(config.OutputRoot, manifest)
+		if err != nil {
+			log.Fatalf("Error writing run manifest: %v", err)
+		}
+		log.Printf("Run manifest written to %s", manifestPath)
+	}
+
+	if backupErr != nil {
+		log.Fatalf("Backup failed: %v", backupErr)
 	}
 
 	log.Println("Backup successful")
+
+	if config.PruneAfterBackup {
+		if err = retentionFunc.Prune(config.StorageConfig, config.Retention, false); err != nil {
+			log.Fatalf("Error pruning backups: %v", err)
+		}
+	}
 }
 
-func addPath(version string) (bool, error) {
-	if !isAdmin() {
+func addPath(binDir string) (bool, error) {
+	if platformFunc.Current.NeedsElevation() {
 		log.Println("Requesting admin privileges to add PostgreSQL to PATH...")
-		err := runAsAdmin()
+		err := platformFunc.Current.Elevate(append(os.Args[1:], "--elevated"))
 		if err != nil {
 			return false, fmt.Errorf("error requesting admin privileges: %v", err)
 		}
 		return true, nil
 	}
 
-	customPath := "C:\\Program Files\\PostgreSQL\\" + version + "\\bin"
-	err := addToSystemPath(customPath)
+	customPath := binDir
+	err := platformFunc.Current.AddToPath(customPath)
 	if err != nil {
 		return false, fmt.Errorf("error adding custom path to system PATH: %v", err)
 	}
@@ -98,33 +295,3 @@ func addPath(version string) (bool, error) {
 	log.Printf("Custom path added to system PATH: %s\n", customPath)
 	return false, nil
 }
-
-func addToSystemPath(path string) error {
-	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
-		$currentPath = [Environment]::GetEnvironmentVariable('Path', 'Machine');
-		if ($currentPath -notlike '*%s*') {
-			[Environment]::SetEnvironmentVariable('Path', $currentPath + ';%s', 'Machine')
-		}`, path, path))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-func isAdmin() bool {
-	_, err := os.Open("\\\\.\\PHYSICALDRIVE0")
-	return err == nil
-}
-
-func runAsAdmin() error {
-	exe, err := os.Executable()
-	if err != nil {
-		return err
-	}
-
-	// Remove the -Wait flag so the original process can continue
-	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`Start-Process "%s" -ArgumentList "--elevated" -Verb RunAs`, exe))
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
@@ -0,0 +1,154 @@
+package manifestFunc
+
+import (
+	"backup/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// filePrefix and fileSuffix frame the run ID in manifest file names
+const (
+	filePrefix = "manifest-"
+	fileSuffix = ".json"
+)
+
+// NewManifest starts the manifest of a new run made with the given tools
+func NewManifest(creds *model.DatabaseCredentials, serverVersion string, tools []model.ClientTool) (*model.Manifest, error) {
+	startedAt := time.Now()
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("error generating run ID: %v", err)
+	}
+
+	manifest := &model.Manifest{
+		RunID:         startedAt.Format("20060102T150405") + "-" + hex.EncodeToString(suffix),
+		StartedAt:     startedAt,
+		Host:          creds.PgHost,
+		Port:          creds.PgPort,
+		Database:      creds.PgDatabase,
+		ServerVersion: serverVersion,
+		Tools:         tools,
+	}
+	for _, tool := range tools {
+		if tool.Name == "pg_dump" {
+			manifest.PgDumpPath = tool.Path
+			manifest.PgDumpVersion = tool.Version
+		}
+	}
+	return manifest, nil
+}
+
+// Write stores the manifest in root and returns its path. Artifact paths
+// are made relative to root.
+func Write(root string, manifest *model.Manifest) (string, error) {
+	for i, artifact := range manifest.Artifacts {
+		relative, err := filepath.Rel(root, artifact.Path)
+		if err != nil {
+			return "", fmt.Errorf("error making artifact path relative: %v", err)
+		}
+		manifest.Artifacts[i].Path = filepath.ToSlash(relative)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding manifest: %v", err)
+	}
+
+	manifestPath := filepath.Join(root, filePrefix+manifest.RunID+fileSuffix)
+	if err = os.WriteFile(manifestPath, content, 0644); err != nil {
+		return "", fmt.Errorf("error writing manifest: %v", err)
+	}
+	return manifestPath, nil
+}
+
+// Load reads a manifest file
+func Load(manifestPath string) (*model.Manifest, error) {
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	var manifest model.Manifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %v", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+// LoadAll reads every manifest in root, keyed by manifest path
+func LoadAll(root string) (map[string]*model.Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(root, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := map[string]*model.Manifest{}
+	for _, manifestPath := range paths {
+		manifest, err := Load(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		manifests[manifestPath] = manifest
+	}
+	return manifests, nil
+}
+
+// ArtifactPath resolves an artifact path from a manifest to a path on disk
+func ArtifactPath(manifestPath string, artifact model.ManifestArtifact) string {
+	return filepath.Join(filepath.Dir(manifestPath), filepath.FromSlash(artifact.Path))
+}
+
+// HashPath returns the size and SHA-256 of a file. For a directory the hash
+// covers each file's relative name and content in name order.
+func HashPath(path string) (int64, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hash := sha256.New()
+	if !info.IsDir() {
+		size, err := hashFile(hash, path)
+		if err != nil {
+			return 0, "", err
+		}
+		return size, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	var total int64
+	err = filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		hash.Write([]byte(filepath.ToSlash(relative) + "\x00"))
+		size, err := hashFile(hash, filePath)
+		total += size
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return total, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(hash io.Writer, path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(hash, file)
+}
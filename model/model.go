@@ -1,6 +1,44 @@
 package model
 
+import (
+	"backup/versionFunc"
+	"time"
+)
+
 const (
+	BackupsDir                      = "./backups"
+	DefaultSchemas                  = "public,dblog"
+	AllSchemas                      = "all"
+	TimestampLayout                 = "2006_01_02_15_04_05"
+	DefaultMaxParallel              = 4
+	DefaultSpaceWarnPercent         = 80
+	FailureContinue                 = "continue"
+	FailureCancel                   = "cancel"
+	StatusSucceeded                 = "succeeded"
+	StatusFailed                    = "failed"
+	StatusCancelled                 = "cancelled"
+	KindDump                        = "dump"
+	KindGlobals                     = "globals"
+	KindSchema                      = "schema"
+	KindData                        = "data"
+	KindPreData                     = "pre-data"
+	KindDataSection                 = "data-section"
+	KindPostData                    = "post-data"
+	ContentFull                     = "full"
+	ContentSchemaOnly               = "schema-only"
+	ContentDataOnly                 = "data-only"
+	ContentSplitSections            = "split-sections"
+	SectionPreData                  = "pre-data"
+	SectionData                     = "data"
+	SectionPostData                 = "post-data"
+	GlobalsDir                      = "globals"
+	FormatPlain                     = "plain"
+	FormatCustom                    = "custom"
+	FormatDirectory                 = "directory"
+	FormatTar                       = "tar"
+	CompressionNone                 = "none"
+	CompressionGzip                 = "gzip"
+	CompressionZstd                 = "zstd"
 	InstallersDir                   = "./installers"
 	PG_LATEST_VERSION_DOWNLOADS_URL = "https://www.enterprisedb.com/downloads/postgres-postgresql-downloads"
 )
@@ -23,9 +61,264 @@ type CheckPostgresqlLatestVersionModel struct {
 	Supported    bool   `json:"supported"`
 }
 
-type PostgresqlVersion struct {
-	LatestVersionWithMinor *string
-	VersionMinor           *string
-	PatchVersion           *string
-	PsqlUrl                *string
+// PostgresqlRelease is a released PostgreSQL version and its Windows installer
+type PostgresqlRelease struct {
+	Version      versionFunc.Version
+	InstallerURL string
+}
+
+// BackupConfig holds the user settings that decide what gets backed up.
+// Values come from the config file, then environment variables, then flags.
+type BackupConfig struct {
+	StorageConfig
+	// Cluster backs up every database on the server instead of DB_DATABASE
+	Cluster bool `json:"cluster"`
+	// ExcludeDatabases are glob patterns of databases skipped in cluster mode
+	ExcludeDatabases []string `json:"excludeDatabases"`
+	// Schemas is a list of schema names or glob patterns. "all" selects every
+	// user schema, and a leading "!" turns an entry into an exclude pattern.
+	Schemas []string `json:"schemas"`
+	// Format is the default pg_dump format: plain, custom, directory or tar
+	Format string `json:"format"`
+	// Jobs is the default number of parallel pg_dump jobs for directory format
+	Jobs int `json:"jobs"`
+	// Content is the default content mode: full, schema-only, data-only or
+	// split-sections, which dumps pre-data, data and post-data separately
+	Content string `json:"content"`
+	// Compression is the default streaming compressor: none, gzip or zstd
+	Compression string `json:"compression"`
+	// CompressionLevel is the compressor level, 0 for the algorithm default
+	CompressionLevel int `json:"compressionLevel"`
+	// Encryption applies to every artifact of the run
+	Encryption EncryptionConfig `json:"encryption"`
+	// MaxParallel limits how many pg_dump processes run at once
+	MaxParallel int `json:"maxParallel"`
+	// MaxParallelPerHost limits concurrent pg_dump processes against one server
+	MaxParallelPerHost int `json:"maxParallelPerHost"`
+	// FailurePolicy decides what happens to the other schemas when one fails:
+	// "continue" lets them finish, "cancel" stops them
+	FailurePolicy string `json:"failurePolicy"`
+	// SchemaTimeout is the default time limit for dumping one schema, e.g. "30m"
+	SchemaTimeout string `json:"schemaTimeout"`
+	// Retention decides which old backups are pruned
+	Retention RetentionPolicy `json:"retention"`
+	// PruneAfterBackup applies the retention policy after a successful backup
+	PruneAfterBackup bool `json:"pruneAfterBackup"`
+	// RecordRowCounts stores per-table row counts in the manifest for verify
+	RecordRowCounts bool `json:"recordRowCounts"`
+	// SpaceWarnPercent warns when the estimated backup size is more than this
+	// share of the free space. The run is refused when it does not fit at all.
+	SpaceWarnPercent int `json:"spaceWarnPercent"`
+	// SkipSpaceCheck starts the backup without comparing its estimate to the free space
+	SkipSpaceCheck bool `json:"skipSpaceCheck"`
+	// DryRun only reports what would be done
+	DryRun bool `json:"-"`
+	// Globals also dumps roles, memberships and tablespaces with pg_dumpall
+	Globals bool `json:"globals"`
+	// NoRolePasswords leaves role passwords out of the globals dump, which
+	// lets non-superusers dump globals without reading pg_authid
+	NoRolePasswords bool `json:"noRolePasswords"`
+	// PgBinDir is the directory of the client tools to use. When set, tools
+	// are neither searched for nor installed and PATH is left alone.
+	PgBinDir string `json:"pgBinDir"`
+	// Targets override the defaults for schemas matching their pattern
+	Targets []BackupTarget `json:"targets"`
+}
+
+// StorageConfig locates the artifacts. Artifacts are named by rendering the
+// template below the output root, and found again by parsing their names.
+type StorageConfig struct {
+	// OutputRoot is the absolute directory backups are written to
+	OutputRoot string `json:"outputRoot"`
+	// NameTemplate names artifacts relative to the output root with the tokens
+	// {db}, {host}, {port}, {schema}, {ts}, {ts_utc}, {format}, {run_id} and {kind}
+	NameTemplate string `json:"nameTemplate"`
+}
+
+// BackupTarget overrides dump settings for the schemas matching Schema.
+// The first matching target wins, and empty fields keep the defaults.
+type BackupTarget struct {
+	Schema           string `json:"schema"`
+	Format           string `json:"format"`
+	Jobs             int    `json:"jobs"`
+	Content          string `json:"content"`
+	Compression      string `json:"compression"`
+	CompressionLevel int    `json:"compressionLevel"`
+	Timeout          string `json:"timeout"`
+	// Priority orders the jobs, higher priorities are started first
+	Priority int `json:"priority"`
+	// Tables selects the tables of the schema that are dumped
+	Tables TableRules `json:"tables"`
+	// PgBinDir dumps these schemas with the client tools in this directory
+	PgBinDir string `json:"pgBinDir"`
+}
+
+// TableRules select tables within one schema by name or glob pattern. They
+// map to pg_dump's --table, --exclude-table and --exclude-table-data.
+type TableRules struct {
+	// Include dumps only these tables, all tables when empty
+	Include []string `json:"include,omitempty"`
+	// Exclude leaves these tables out entirely
+	Exclude []string `json:"exclude,omitempty"`
+	// ExcludeData dumps the definition of these tables but not their rows
+	ExcludeData []string `json:"excludeData,omitempty"`
+}
+
+// BackupJob is the resolved set of settings used to dump one schema
+type BackupJob struct {
+	Database         string           `json:"database"`
+	Schema           string           `json:"schema"`
+	Format           string           `json:"format"`
+	Jobs             int              `json:"jobs"`
+	Content          string           `json:"content"`
+	Compression      string           `json:"compression"`
+	CompressionLevel int              `json:"compressionLevel"`
+	Encryption       EncryptionConfig `json:"encryption"`
+	Timeout          time.Duration    `json:"timeout,omitempty"`
+	Priority         int              `json:"priority"`
+	TableRules       TableRules       `json:"tableRules"`
+	// Section is set on the jobs a split-sections dump is broken into
+	Section string `json:"section,omitempty"`
+	// Snapshot is the exported snapshot shared by the dumps of the database
+	Snapshot string `json:"snapshot,omitempty"`
+	// EstimatedBytes is the expected artifact size from the pre-flight estimate
+	EstimatedBytes int64 `json:"estimatedBytes"`
+	// Tables, TablesWithoutData and ExcludedTables list the tables the rules resolved to
+	Tables            []string `json:"tables,omitempty"`
+	TablesWithoutData []string `json:"tablesWithoutData,omitempty"`
+	ExcludedTables    []string `json:"excludedTables,omitempty"`
+	// OutputRoot and NameTemplate decide where the artifact is written
+	OutputRoot   string `json:"outputRoot"`
+	NameTemplate string `json:"nameTemplate"`
+	// PgBinDir is the client tools directory, empty for the detected tools
+	PgBinDir string `json:"pgBinDir,omitempty"`
+}
+
+// EncryptionConfig selects how artifacts are encrypted with age. Either a
+// passphrase or a list of X25519 public keys ("age1...") can be used, so
+// the backup host never needs the private key.
+type EncryptionConfig struct {
+	Passphrase string   `json:"-"`
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+// DecryptionConfig holds what is needed to read encrypted artifacts back
+type DecryptionConfig struct {
+	IdentityFile string
+	Passphrase   string
+}
+
+// CatalogEntry describes one backup artifact found under the backups directory
+type CatalogEntry struct {
+	// ID is the artifact path relative to the output root without extensions.
+	// Series is the name without time and kind, shared by every run's copy.
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Path        string    `json:"path"`
+	Schema      string    `json:"schema"`
+	DataSource  string    `json:"dataSource"`
+	Series      string    `json:"series"`
+	Timestamp   time.Time `json:"timestamp"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression"`
+	Encrypted   bool      `json:"encrypted"`
+	// RunID and ManifestPath are set when the artifact is listed in a run manifest
+	RunID        string `json:"runId,omitempty"`
+	ManifestPath string `json:"manifestPath,omitempty"`
+	Database     string `json:"database,omitempty"`
+}
+
+// RestoreOptions control how a backup is replayed into the target database
+type RestoreOptions struct {
+	// Clean drops the archive's objects before restoring, pg_restore only
+	Clean bool
+	// SingleTransaction restores everything or nothing
+	SingleTransaction bool
+	// Jobs is the number of parallel pg_restore jobs for directory dumps
+	Jobs int
+}
+
+// RetentionPolicy keeps the newest KeepLast backups plus the newest backup of
+// each of the last KeepDaily days, KeepWeekly weeks and KeepMonthly months.
+// A policy with every field at zero keeps everything.
+type RetentionPolicy struct {
+	KeepLast    int `json:"keepLast"`
+	KeepDaily   int `json:"keepDaily"`
+	KeepWeekly  int `json:"keepWeekly"`
+	KeepMonthly int `json:"keepMonthly"`
+}
+
+// RetentionDecision records whether a backup is kept and which rules keep it
+type RetentionDecision struct {
+	Entry   CatalogEntry
+	Keep    bool
+	Reasons []string
+}
+
+// Manifest describes one backup run and every artifact it produced. It is
+// written as JSON next to the artifacts, which it references by relative path.
+type Manifest struct {
+	RunID         string    `json:"runId"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Host          string    `json:"host"`
+	Port          string    `json:"port"`
+	Database      string    `json:"database"`
+	ServerVersion string    `json:"serverVersion"`
+	PgDumpPath    string    `json:"pgDumpPath"`
+	PgDumpVersion string    `json:"pgDumpVersion"`
+	// Tools are the client tools of the run, each probed on its own
+	Tools     []ClientTool       `json:"tools"`
+	Cluster   bool               `json:"cluster"`
+	Results   []SchemaResult     `json:"results"`
+	Artifacts []ManifestArtifact `json:"artifacts"`
+}
+
+// ManifestArtifact describes one artifact of a run. Directory dumps are
+// hashed over their files in name order.
+type ManifestArtifact struct {
+	// Kind tells what the artifact holds: "dump" for full schema dumps,
+	// "schema", "data", "pre-data", "data-section", "post-data" for the
+	// content modes and "globals" for roles and tablespaces
+	Kind            string    `json:"kind"`
+	Database        string    `json:"database"`
+	Schema          string    `json:"schema"`
+	Path            string    `json:"path"`
+	Size            int64     `json:"size"`
+	SHA256          string    `json:"sha256"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Job             BackupJob `json:"job"`
+	Args            []string  `json:"args"`
+	// RowCounts holds the exact row count of each table at dump time
+	RowCounts map[string]int64 `json:"rowCounts,omitempty"`
+}
+
+// VerifyResult lists the checks run against one artifact and what failed
+type VerifyResult struct {
+	Entry    CatalogEntry
+	Passed   []string
+	Problems []string
+}
+
+// SchemaResult reports how the backup of one schema went
+type SchemaResult struct {
+	Kind            string  `json:"kind"`
+	Database        string  `json:"database"`
+	Schema          string  `json:"schema"`
+	Status          string  `json:"status"`
+	ArtifactPath    string  `json:"artifactPath,omitempty"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+	// StderrExcerpt holds the last lines pg_dump wrote to stderr
+	StderrExcerpt string `json:"stderrExcerpt,omitempty"`
+}
+
+// ClientTool is a PostgreSQL client tool and the version it reports
+type ClientTool struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Major   string `json:"major"`
 }
@@ -0,0 +1,317 @@
+package namingFunc
+
+import (
+	"backup/model"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTemplate is the layout used when no template is configured
+	DefaultTemplate = "{schema}/{db}_{host}-{ts}-{kind}"
+	// DefaultClusterTemplate gives every database its own subtree in cluster mode
+	DefaultClusterTemplate = "{db}/{schema}/{db}_{host}-{ts}-{kind}"
+	// GlobalsTemplate names the globals artifacts, which belong to no schema
+	GlobalsTemplate = model.GlobalsDir + "/{host}-{ts}-{kind}"
+	// utcLayout formats the {ts_utc} token
+	utcLayout = "20060102T150405Z"
+)
+
+// tokens maps every template token to the pattern its value is parsed with.
+// Underscores in hosts are escaped when rendering, so {db}_{host} splits at
+// the last one, except for IPv4 addresses written by older versions.
+var tokens = map[string]string{
+	"db":     `[^/]+?`,
+	"host":   `\d+_\d+_\d+_\d+|[^/_]+`,
+	"port":   `\d+`,
+	"schema": `[^/]+?`,
+	"ts":     `\d{4}_\d{2}_\d{2}_\d{2}_\d{2}_\d{2}`,
+	"ts_utc": `\d{8}T\d{6}Z`,
+	"format": `plain|custom|directory|tar`,
+	"run_id": `\d{8}T\d{6}-[0-9a-f]{6}`,
+	"kind":   `dump|globals|schema|data|pre-data|data-section|post-data`,
+}
+
+var tokenPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// looseHost parses the unescaped hosts of older names where pinned values
+// delimit them, as in the cluster layout
+const looseHost = `[^/]+?`
+
+// legacyAddress is an IPv4 address with its dots replaced by underscores
+var legacyAddress = regexp.MustCompile(`^\d+_\d+_\d+_\d+$`)
+
+// hostEscaper percent-encodes the characters that would make a host split
+// differently or add a directory, e.g. in compose service names like pg_primary
+var hostEscaper = strings.NewReplacer("%", "%25", "_", "%5F", "/", "%2F", `\`, "%5C")
+
+// Values are the parts an artifact name is made of
+type Values struct {
+	Database string
+	Host     string
+	Port     string
+	Schema   string
+	Format   string
+	RunID    string
+	Kind     string
+	// Time is the start of the run
+	Time time.Time
+}
+
+// Template renders artifact names relative to the output root and parses
+// them back. Extensions are not part of the template.
+type Template struct {
+	pattern string
+	regex   *regexp.Regexp
+	// repeated is set when a token is used more than once
+	repeated bool
+}
+
+// Parse checks a template and compiles the expression used to read names
+// back. A template without {kind} gets "-{kind}" appended, so the artifacts
+// of different content modes never collide.
+func Parse(pattern string) (*Template, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("naming template is empty")
+	}
+	if path.IsAbs(pattern) || strings.Contains(pattern, `\`) {
+		return nil, fmt.Errorf("naming template %q must be a relative path using /", pattern)
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("naming template %q has an empty or relative path segment", pattern)
+		}
+	}
+	if !strings.Contains(pattern, "{kind}") {
+		pattern += "-{kind}"
+	}
+
+	if strings.ContainsAny(tokenPattern.ReplaceAllString(pattern, ""), "{}") {
+		return nil, fmt.Errorf("naming template %q has an unclosed token", pattern)
+	}
+	for _, match := range tokenPattern.FindAllStringSubmatch(pattern, -1) {
+		if _, ok := tokens[match[1]]; !ok {
+			return nil, fmt.Errorf("unknown token {%s} in naming template %q", match[1], pattern)
+		}
+	}
+
+	template := &Template{pattern: pattern}
+	used := map[string]bool{}
+	for _, match := range tokenPattern.FindAllStringSubmatch(pattern, -1) {
+		template.repeated = template.repeated || used[match[1]]
+		used[match[1]] = true
+	}
+	regex, err := template.compile(nil, tokens["host"])
+	if err != nil {
+		return nil, fmt.Errorf("error compiling naming template %q: %v", pattern, err)
+	}
+	template.regex = regex
+	return template, nil
+}
+
+// compile builds the expression matching the template, parsing hosts with
+// the host pattern. Only the first use of a token captures it, later uses
+// match the value in fixed when given.
+func (t *Template) compile(fixed map[string]string, host string) (*regexp.Regexp, error) {
+	var expression strings.Builder
+	expression.WriteString(`(?:^|/)`)
+	seen := map[string]bool{}
+	last := 0
+	for _, match := range tokenPattern.FindAllStringSubmatchIndex(t.pattern, -1) {
+		token := t.pattern[match[2]:match[3]]
+		expression.WriteString(regexp.QuoteMeta(t.pattern[last:match[0]]))
+		pattern := tokens[token]
+		if token == "host" {
+			pattern = host
+		}
+		switch value, ok := fixed[token]; {
+		case !seen[token]:
+			expression.WriteString("(?P<" + token + ">" + pattern + ")")
+		case ok:
+			expression.WriteString(regexp.QuoteMeta(value))
+		default:
+			expression.WriteString("(?:" + pattern + ")")
+		}
+		seen[token] = true
+		last = match[1]
+	}
+	expression.WriteString(regexp.QuoteMeta(t.pattern[last:]))
+	expression.WriteString(`$`)
+	return regexp.Compile(expression.String())
+}
+
+// String returns the template, including an appended {kind}
+func (t *Template) String() string {
+	return t.pattern
+}
+
+// Uses reports whether the template contains the token
+func (t *Template) Uses(token string) bool {
+	return strings.Contains(t.pattern, "{"+token+"}")
+}
+
+// Render fills in the tokens. Path separators in values are replaced, so a
+// value never adds a directory, and the host is escaped with hostEscaper.
+func (t *Template) Render(values Values) string {
+	return tokenPattern.ReplaceAllStringFunc(t.pattern, func(token string) string {
+		var value string
+		switch token {
+		case "{db}":
+			value = values.Database
+		case "{host}":
+			value = hostEscaper.Replace(values.Host)
+		case "{port}":
+			value = values.Port
+		case "{schema}":
+			value = values.Schema
+		case "{ts}":
+			value = values.Time.Format(model.TimestampLayout)
+		case "{ts_utc}":
+			value = values.Time.UTC().Format(utcLayout)
+		case "{format}":
+			value = values.Format
+		case "{run_id}":
+			value = values.RunID
+		case "{kind}":
+			value = values.Kind
+		}
+		return strings.NewReplacer("/", "_", `\`, "_").Replace(value)
+	})
+}
+
+// Series renders the name with the run's time, run ID and kind left out.
+// Artifacts of one series are the same dump taken by different runs.
+func (t *Template) Series(values Values) string {
+	pattern := t.pattern
+	for _, token := range []string{"{ts}", "{ts_utc}", "{run_id}", "{kind}"} {
+		pattern = strings.ReplaceAll(pattern, token, "*")
+	}
+	return (&Template{pattern: pattern}).Render(values)
+}
+
+// Match reads the values back from a name without extensions. The name may
+// be relative to the output root or any path ending in a templated name.
+// The matched part of the name is returned too.
+func (t *Template) Match(name string) (Values, string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	matches := t.regex.FindStringSubmatch(name)
+	if matches == nil {
+		return Values{}, "", false
+	}
+
+	found := captured(t.regex, matches)
+
+	// Match again with repeated tokens pinned to their first value, since
+	// each use could otherwise split the name differently. Only the pinned
+	// values delimit the unescaped hosts of older names.
+	if t.repeated {
+		var regex *regexp.Regexp
+		for _, host := range []string{tokens["host"], looseHost} {
+			var err error
+			if regex, err = t.compile(found, host); err != nil {
+				return Values{}, "", false
+			}
+			if matches = regex.FindStringSubmatch(name); matches != nil {
+				break
+			}
+		}
+		if matches == nil {
+			return Values{}, "", false
+		}
+		found = captured(regex, matches)
+	}
+
+	values := Values{
+		Database: found["db"],
+		Host:     found["host"],
+		Port:     found["port"],
+		Schema:   found["schema"],
+		Format:   found["format"],
+		RunID:    found["run_id"],
+		Kind:     found["kind"],
+	}
+	if host, err := url.PathUnescape(values.Host); err == nil {
+		values.Host = host
+	}
+	if legacyAddress.MatchString(values.Host) {
+		values.Host = strings.ReplaceAll(values.Host, "_", ".")
+	}
+
+	var err error
+	switch {
+	case found["ts"] != "":
+		values.Time, err = time.ParseInLocation(model.TimestampLayout, found["ts"], time.Local)
+	case found["ts_utc"] != "":
+		values.Time, err = time.Parse(utcLayout, found["ts_utc"])
+	case found["run_id"] != "":
+		values.Time, err = time.ParseInLocation("20060102T150405", strings.SplitN(found["run_id"], "-", 2)[0], time.Local)
+	}
+	if err != nil {
+		return Values{}, "", false
+	}
+
+	return values, strings.TrimPrefix(matches[0], "/"), true
+}
+
+func captured(regex *regexp.Regexp, matches []string) map[string]string {
+	found := map[string]string{}
+	for i, token := range regex.SubexpNames() {
+		if token != "" {
+			found[token] = matches[i]
+		}
+	}
+	return found
+}
+
+// Templates returns the templates artifacts are named and parsed with. The
+// configured template comes first, without one both default layouts are
+// recognized. Globals always use GlobalsTemplate.
+func Templates(nameTemplate string) ([]*Template, error) {
+	patterns := []string{nameTemplate}
+	if nameTemplate == "" {
+		patterns = []string{DefaultClusterTemplate, DefaultTemplate}
+	}
+	patterns = append(patterns, GlobalsTemplate)
+
+	var templates []*Template
+	for _, pattern := range patterns {
+		template, err := Parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// ForJobs returns the template schema dumps are written with
+func ForJobs(nameTemplate string, cluster bool) (*Template, error) {
+	switch {
+	case nameTemplate != "":
+	case cluster:
+		nameTemplate = DefaultClusterTemplate
+	default:
+		nameTemplate = DefaultTemplate
+	}
+
+	template, err := Parse(nameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every run and every schema needs its own name
+	if !template.Uses("ts") && !template.Uses("ts_utc") && !template.Uses("run_id") {
+		return nil, fmt.Errorf("naming template %q needs {ts}, {ts_utc} or {run_id}", nameTemplate)
+	}
+	if !template.Uses("schema") {
+		return nil, fmt.Errorf("naming template %q needs {schema}", nameTemplate)
+	}
+	if cluster && !template.Uses("db") {
+		return nil, fmt.Errorf("naming template %q needs {db} in cluster mode", nameTemplate)
+	}
+	return template, nil
+}
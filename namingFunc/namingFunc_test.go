@@ -0,0 +1,144 @@
+package namingFunc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderMatch(t *testing.T) {
+	at := time.Date(2024, 3, 10, 18, 30, 5, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		template string
+		values   Values
+		want     string
+	}{
+		{
+			name:     "default",
+			template: DefaultTemplate,
+			values:   Values{Database: "mydb", Host: "localhost", Schema: "public", Kind: "dump", Time: at},
+			want:     "public/mydb_localhost-2024_03_10_18_30_05-dump",
+		},
+		{
+			name:     "default with underscores in the database",
+			template: DefaultTemplate,
+			values:   Values{Database: "my_db", Host: "10.0.0.1", Schema: "public", Kind: "dump", Time: at},
+			want:     "public/my_db_10.0.0.1-2024_03_10_18_30_05-dump",
+		},
+		{
+			name:     "default with a dashed host name",
+			template: DefaultTemplate,
+			values:   Values{Database: "app_db", Host: "db-1.example.com", Schema: "sales_2024", Kind: "pre-data", Time: at},
+			want:     "sales_2024/app_db_db-1.example.com-2024_03_10_18_30_05-pre-data",
+		},
+		{
+			name:     "default with an underscore in the host",
+			template: DefaultTemplate,
+			values:   Values{Database: "app", Host: "pg_primary", Schema: "public", Kind: "dump", Time: at},
+			want:     "public/app_pg%5Fprimary-2024_03_10_18_30_05-dump",
+		},
+		{
+			name:     "cluster",
+			template: DefaultClusterTemplate,
+			values:   Values{Database: "my_db", Host: "db.example.com", Schema: "public", Kind: "data-section", Time: at},
+			want:     "my_db/public/my_db_db.example.com-2024_03_10_18_30_05-data-section",
+		},
+		{
+			name:     "cluster with an underscore in the host",
+			template: DefaultClusterTemplate,
+			values:   Values{Database: "app", Host: "pg_primary", Schema: "public", Kind: "dump", Time: at},
+			want:     "app/public/app_pg%5Fprimary-2024_03_10_18_30_05-dump",
+		},
+		{
+			name:     "custom with port and UTC time",
+			template: "{host}_{port}/{db}/{schema}/{ts_utc}",
+			values:   Values{Database: "my_db", Host: "10.0.0.1", Port: "5432", Schema: "public", Kind: "schema", Time: at},
+			want:     "10.0.0.1_5432/my_db/public/" + at.UTC().Format(utcLayout) + "-schema",
+		},
+		{
+			name:     "custom with format and run ID",
+			template: "{db}/{schema}-{format}-{run_id}-{kind}",
+			values:   Values{Database: "my-db", Schema: "public", Format: "custom", RunID: "20240310T183005-a1b2c3", Kind: "data", Time: at},
+			want:     "my-db/public-custom-20240310T183005-a1b2c3-data",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := Parse(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			name := template.Render(test.values)
+			if name != test.want {
+				t.Fatalf("Render() = %q, want %q", name, test.want)
+			}
+
+			values, matched, ok := template.Match("/var/backups/" + name)
+			if !ok {
+				t.Fatalf("Match(%q) did not match", name)
+			}
+			if matched != name {
+				t.Errorf("Match() matched %q, want %q", matched, name)
+			}
+			if !values.Time.Equal(test.values.Time) {
+				t.Errorf("Match() time = %v, want %v", values.Time, test.values.Time)
+			}
+			values.Time, test.values.Time = time.Time{}, time.Time{}
+			if values != test.values {
+				t.Errorf("Match() = %+v, want %+v", values, test.values)
+			}
+		})
+	}
+}
+
+func TestMatchLegacy(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		match    string
+		database string
+		host     string
+	}{
+		{
+			name:     "default with an IPv4 address",
+			template: DefaultTemplate,
+			match:    "public/my_db_10_0_0_1-2024_03_10_18_30_05-dump",
+			database: "my_db",
+			host:     "10.0.0.1",
+		},
+		{
+			name:     "cluster with an IPv4 address",
+			template: DefaultClusterTemplate,
+			match:    "my_db/public/my_db_10_0_0_1-2024_03_10_18_30_05-dump",
+			database: "my_db",
+			host:     "10.0.0.1",
+		},
+		{
+			name:     "cluster with an unescaped underscore in the host",
+			template: DefaultClusterTemplate,
+			match:    "app/public/app_pg_primary-2024_03_10_18_30_05-dump",
+			database: "app",
+			host:     "pg_primary",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := Parse(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values, _, ok := template.Match(test.match)
+			if !ok {
+				t.Fatalf("Match(%q) did not match", test.match)
+			}
+			if values.Database != test.database || values.Host != test.host {
+				t.Errorf("Match() = database %q, host %q, want %q, %q", values.Database, values.Host, test.database, test.host)
+			}
+		})
+	}
+}
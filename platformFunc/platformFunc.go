@@ -0,0 +1,49 @@
+package platformFunc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Platform holds what the tool does differently per operating system:
+// elevation, PATH changes and running the PostgreSQL installer
+type Platform interface {
+	// NeedsElevation reports whether the process must be relaunched with
+	// admin privileges before it can add the client tools to PATH
+	NeedsElevation() bool
+	// Elevate relaunches the tool with admin privileges and args
+	Elevate(args []string) error
+	// AddToPath makes the tools in dir available on PATH
+	AddToPath(dir string) error
+	// ToolDirs returns glob patterns of the directories where client tools
+	// are installed in the platform's standard layouts
+	ToolDirs() []string
+	// Executable returns the file name of a client tool such as pg_dump
+	Executable(tool string) string
+	// InstallDir returns where the installer puts a major version, or an
+	// error when the tools cannot be installed automatically
+	InstallDir(version string) (string, error)
+	// RunInstaller runs a downloaded installer unattended into installDir
+	RunInstaller(installer, installDir string) error
+}
+
+// Current is the platform the tool was built for
+var Current Platform = current
+
+// ToolPath returns the path of a client tool in binDir
+func ToolPath(binDir, tool string) string {
+	return filepath.Join(binDir, Current.Executable(tool))
+}
+
+// prependPath puts dir in front of this process's PATH, the system
+// configuration is left alone
+func prependPath(dir string) error {
+	path := os.Getenv("PATH")
+	for _, entry := range filepath.SplitList(path) {
+		if entry == dir {
+			return nil
+		}
+	}
+	return os.Setenv("PATH", strings.Join([]string{dir, path}, string(os.PathListSeparator)))
+}
@@ -0,0 +1,48 @@
+//go:build darwin
+
+package platformFunc
+
+import "fmt"
+
+var current Platform = darwinPlatform{}
+
+type darwinPlatform struct{}
+
+// NeedsElevation is false, PATH is only changed for this process
+func (darwinPlatform) NeedsElevation() bool {
+	return false
+}
+
+func (darwinPlatform) Elevate(args []string) error {
+	return fmt.Errorf("relaunching with admin privileges is not supported on macOS")
+}
+
+func (darwinPlatform) AddToPath(dir string) error {
+	return prependPath(dir)
+}
+
+// ToolDirs covers Homebrew on Apple silicon and Intel, Postgres.app and
+// the EDB installer
+func (darwinPlatform) ToolDirs() []string {
+	return []string{
+		"/opt/homebrew/opt/postgresql@*/bin",
+		"/opt/homebrew/opt/libpq/bin",
+		"/usr/local/opt/postgresql@*/bin",
+		"/usr/local/opt/libpq/bin",
+		"/Applications/Postgres.app/Contents/Versions/*/bin",
+		"/Library/PostgreSQL/*/bin",
+	}
+}
+
+func (darwinPlatform) Executable(tool string) string {
+	return tool
+}
+
+// InstallDir always fails, the client tools come from Homebrew
+func (darwinPlatform) InstallDir(version string) (string, error) {
+	return "", fmt.Errorf("PostgreSQL %s client tools are not installed and cannot be installed automatically on macOS, run brew install postgresql@%s", version, version)
+}
+
+func (darwinPlatform) RunInstaller(installer, installDir string) error {
+	return fmt.Errorf("running installers is not supported on macOS")
+}
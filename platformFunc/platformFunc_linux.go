@@ -0,0 +1,47 @@
+//go:build linux
+
+package platformFunc
+
+import "fmt"
+
+var current Platform = linuxPlatform{}
+
+type linuxPlatform struct{}
+
+// NeedsElevation is false, PATH is only changed for this process
+func (linuxPlatform) NeedsElevation() bool {
+	return false
+}
+
+func (linuxPlatform) Elevate(args []string) error {
+	return fmt.Errorf("relaunching with admin privileges is not supported on Linux, run the tool as a user that can read the backup directory")
+}
+
+func (linuxPlatform) AddToPath(dir string) error {
+	return prependPath(dir)
+}
+
+// ToolDirs covers the Debian and Ubuntu packages, the PGDG packages for
+// RHEL and Fedora, and Homebrew on Linux
+func (linuxPlatform) ToolDirs() []string {
+	return []string{
+		"/usr/lib/postgresql/*/bin",
+		"/usr/pgsql-*/bin",
+		"/home/linuxbrew/.linuxbrew/opt/postgresql@*/bin",
+		"/home/linuxbrew/.linuxbrew/opt/libpq/bin",
+	}
+}
+
+func (linuxPlatform) Executable(tool string) string {
+	return tool
+}
+
+// InstallDir always fails, the client tools come from the distribution's
+// package manager
+func (linuxPlatform) InstallDir(version string) (string, error) {
+	return "", fmt.Errorf("PostgreSQL %s client tools are not installed and cannot be installed automatically on Linux, install postgresql-client-%s (Debian, Ubuntu) or postgresql%s (RHEL, Fedora)", version, version, version)
+}
+
+func (linuxPlatform) RunInstaller(installer, installDir string) error {
+	return fmt.Errorf("running installers is not supported on Linux")
+}
@@ -0,0 +1,43 @@
+//go:build !linux && !darwin && !windows
+
+package platformFunc
+
+import "fmt"
+
+var current Platform = otherPlatform{}
+
+// otherPlatform covers operating systems without a standard layout for the
+// client tools, such as the BSDs
+type otherPlatform struct{}
+
+// NeedsElevation is false, PATH is only changed for this process
+func (otherPlatform) NeedsElevation() bool {
+	return false
+}
+
+func (otherPlatform) Elevate(args []string) error {
+	return fmt.Errorf("relaunching with admin privileges is not supported on this platform")
+}
+
+func (otherPlatform) AddToPath(dir string) error {
+	return prependPath(dir)
+}
+
+// ToolDirs is empty, the client tools are only found on PATH or in the
+// configured client tools directory
+func (otherPlatform) ToolDirs() []string {
+	return nil
+}
+
+func (otherPlatform) Executable(tool string) string {
+	return tool
+}
+
+// InstallDir always fails, the client tools come from the system's packages
+func (otherPlatform) InstallDir(version string) (string, error) {
+	return "", fmt.Errorf("PostgreSQL %s client tools are not installed and cannot be installed automatically on this platform, install them and put them on PATH or set PG_BIN_DIR", version)
+}
+
+func (otherPlatform) RunInstaller(installer, installDir string) error {
+	return fmt.Errorf("running installers is not supported on this platform")
+}
@@ -0,0 +1,104 @@
+//go:build windows
+
+package platformFunc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// installRoot is where the EDB installer puts every major version
+const installRoot = "C:\\Program Files\\PostgreSQL\\"
+
+var current Platform = windowsPlatform{}
+
+type windowsPlatform struct{}
+
+// NeedsElevation is true unless the process already runs as administrator,
+// since the machine PATH can only be changed by one
+func (windowsPlatform) NeedsElevation() bool {
+	_, err := os.Open("\\\\.\\PHYSICALDRIVE0")
+	return err != nil
+}
+
+func (windowsPlatform) Elevate(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// Pass the original arguments through so the elevated process uses the same settings
+	var arguments []string
+	for _, arg := range args {
+		arguments = append(arguments, "'"+strings.ReplaceAll(arg, "'", "''")+"'")
+	}
+
+	// Remove the -Wait flag so the original process can continue
+	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`Start-Process "%s" -ArgumentList %s -Verb RunAs`, exe, strings.Join(arguments, ",")))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// AddToPath adds dir to the machine PATH if it is not there yet
+func (windowsPlatform) AddToPath(dir string) error {
+	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
+		$currentPath = [Environment]::GetEnvironmentVariable('Path', 'Machine');
+		if ($currentPath -notlike '*%s*') {
+			[Environment]::SetEnvironmentVariable('Path', $currentPath + ';%s', 'Machine')
+		}`, dir, dir))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (windowsPlatform) ToolDirs() []string {
+	return []string{installRoot + "*\\bin"}
+}
+
+func (windowsPlatform) Executable(tool string) string {
+	return tool + ".exe"
+}
+
+func (windowsPlatform) InstallDir(version string) (string, error) {
+	return installRoot + version, nil
+}
+
+// RunInstaller runs the installer through a batch file without a console
+// window and removes the installer afterwards
+func (windowsPlatform) RunInstaller(installer, installDir string) error {
+	batchFile := "install.bat"
+	content := fmt.Sprintf(`@echo off 
+"%s" --mode unattended --prefix "%s"
+`, installer, installDir)
+
+	err := os.WriteFile(batchFile, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(batchFile)
+
+	cmd := exec.Command("cmd", "/c", batchFile)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(installer); err != nil {
+		return fmt.Errorf("error removing exe file: %v", err)
+	}
+
+	mess := fmt.Sprintln("Installer removed after installation.")
+	log.Println(mess)
+
+	return nil
+}
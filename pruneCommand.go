@@ -0,0 +1,19 @@
+package main
+
+import (
+	"backup/config/backupConfig"
+	"backup/retentionFunc"
+	"log"
+)
+
+// runPrune applies the retention policy to the backups directory on its own
+func runPrune(args []string) {
+	config, err := backupConfig.ScanBackupConfig(args)
+	if err != nil {
+		log.Fatalf("Error reading backup configuration: %v", err)
+	}
+
+	if err = retentionFunc.Prune(config.StorageConfig, config.Retention, config.DryRun); err != nil {
+		log.Fatalf("Error pruning backups: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"backup/catalogFunc"
+	"backup/config/backupConfig"
+	"backup/config/dbconfig"
+	"backup/config/locateTools"
+	"backup/model"
+	"backup/restoreFunc"
+	"flag"
+	"log"
+)
+
+// runRestore replays a backup, given by path or catalog ID, into a target database
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	host := flags.String("host", "", "target host, defaults to DB_HOST")
+	port := flags.String("port", "", "target port, defaults to DB_PORT")
+	database := flags.String("dbname", "", "target database, defaults to DB_DATABASE")
+	user := flags.String("username", "", "target user, defaults to DB_USERNAME")
+	create := flags.Bool("create", false, "create the target database if it does not exist")
+	clean := flags.Bool("clean", false, "drop existing objects before restoring")
+	singleTransaction := flags.Bool("single-transaction", false, "restore in a single transaction")
+	jobs := flags.Int("jobs", 0, "parallel pg_restore jobs, directory dumps only")
+	identityFile := flags.String("identity", "", "age identity file for encrypted backups")
+	withGlobals := flags.Bool("with-globals", false, "restore the roles and tablespaces taken by the same run first, resetting role passwords and attributes")
+	scanStorage := backupConfig.StorageFlags(flags)
+	pgBin := backupConfig.PgBinFlag(flags)
+	_ = flags.Parse(args)
+
+	storage, err := scanStorage()
+	if err != nil {
+		log.Fatalf("Error reading backup configuration: %v", err)
+	}
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: restore [options] <backup path or catalog ID>")
+	}
+
+	entry, err := catalogFunc.Find(storage, flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Error finding backup: %v", err)
+	}
+
+	// A split dump is restored section by section, indexes and constraints last
+	artifacts := []model.CatalogEntry{entry}
+	if catalogFunc.IsSection(entry) {
+		if artifacts, err = catalogFunc.Sections(storage, entry); err != nil {
+			log.Fatalf("Error finding backup: %v", err)
+		}
+	}
+
+	// Cleaning drops the tables a data-only restore loads into
+	if *clean && (artifacts[0].Kind == model.KindData || artifacts[0].Kind == model.KindDataSection) {
+		log.Fatalf("--clean cannot be used with the data-only backup %s, restore its schema first", entry.ID)
+	}
+
+	// Only pg_restore knows which objects a dump contains and drops just those
+	if *clean && artifacts[0].Format == model.FormatPlain {
+		log.Fatalf("--clean cannot be used with the plain dump %s, drop the schema's objects first or restore into a new database", entry.ID)
+	}
+
+	// Default the target to the configured credentials
+	creds, err := dbconfig.ScanCredsInformation()
+	if err != nil {
+		log.Fatalf("Error scanning credentials: %v", err)
+	}
+	overrideString(&creds.PgHost, *host)
+	overrideString(&creds.PgPort, *port)
+	overrideString(&creds.PgDatabase, *database)
+	overrideString(&creds.PgUser, *user)
+
+	decryption := backupConfig.ScanDecryptionConfig(*identityFile)
+
+	// Globals are restored first so the roles owning the restored objects exist
+	globals, hasGlobals := entry, entry.Kind == model.KindGlobals
+	if !hasGlobals && *withGlobals {
+		globals, hasGlobals, err = catalogFunc.FindGlobals(storage, entry)
+		if err != nil {
+			log.Fatalf("Error finding globals: %v", err)
+		}
+	}
+
+	var tools locateTools.Installation
+	if hasGlobals || *create {
+		maintenance := *creds
+		maintenance.PgDatabase = "postgres"
+		maintenanceDB, err := dbconfig.CheckDatabaseConnection(&maintenance)
+		if err != nil {
+			log.Fatalf("Database connection failed: %v", err)
+		}
+
+		if hasGlobals {
+			if tools, _, err = clientTools(maintenanceDB, *pgBin); err == nil {
+				err = restoreFunc.RestoreGlobals(creds, tools.BinDir, globals, decryption)
+			}
+			if err != nil {
+				maintenanceDB.Close()
+				log.Fatalf("Restoring globals failed: %v", err)
+			}
+			if entry.Kind == model.KindGlobals {
+				maintenanceDB.Close()
+				log.Println("Restore successful")
+				return
+			}
+		}
+
+		if *create {
+			err = restoreFunc.CreateDatabaseIfNotExists(maintenanceDB, creds.PgDatabase)
+		}
+		maintenanceDB.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	db, err := dbconfig.CheckDatabaseConnection(creds)
+	if err != nil {
+		log.Fatalf("Database connection failed: %v", err)
+	}
+	defer db.Close()
+
+	if tools.BinDir == "" {
+		if tools, _, err = clientTools(db, *pgBin); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	options := model.RestoreOptions{
+		Clean:             *clean,
+		SingleTransaction: *singleTransaction,
+		Jobs:              *jobs,
+	}
+	for _, artifact := range artifacts {
+		if err = restoreFunc.RestoreBackup(creds, tools.BinDir, artifact, options, decryption); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		// Only the first section drops existing objects
+		options.Clean = false
+	}
+
+	log.Println("Restore successful")
+}
+
+func overrideString(setting *string, value string) {
+	if value != "" {
+		*setting = value
+	}
+}
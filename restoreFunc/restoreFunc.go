@@ -0,0 +1,167 @@
+package restoreFunc
+
+import (
+	"backup/compressFunc"
+	"backup/encryptFunc"
+	"backup/model"
+	"backup/platformFunc"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// RestoreBackup replays a backup artifact into the database named in creds.
+// Plain dumps are fed to psql, archive formats to pg_restore.
+func RestoreBackup(creds *model.DatabaseCredentials, binDir string, entry model.CatalogEntry, options model.RestoreOptions, decryption model.DecryptionConfig) error {
+	var args []string
+	var tool string
+
+	if entry.Format == model.FormatPlain {
+		tool = "psql"
+		args = []string{"--set", "ON_ERROR_STOP=1", "--quiet"}
+	} else {
+		tool = "pg_restore"
+		args = []string{"--exit-on-error"}
+		if options.Clean {
+			args = append(args, "--clean", "--if-exists")
+		}
+		if options.Jobs > 1 {
+			if entry.Format != model.FormatDirectory {
+				return fmt.Errorf("parallel restore is only supported for directory dumps")
+			}
+			args = append(args, fmt.Sprintf("--jobs=%d", options.Jobs))
+		}
+	}
+
+	if options.SingleTransaction {
+		if options.Jobs > 1 {
+			return fmt.Errorf("single transaction restore cannot use parallel jobs")
+		}
+		args = append(args, "--single-transaction")
+	}
+
+	args = append(args,
+		fmt.Sprintf("--username=%s", creds.PgUser),
+		fmt.Sprintf("--host=%s", creds.PgHost),
+		fmt.Sprintf("--port=%s", creds.PgPort),
+		fmt.Sprintf("--dbname=%s", creds.PgDatabase),
+	)
+
+	log.Printf("Restoring %s into database %s with %s", entry.Path, creds.PgDatabase, tool)
+	return runRestoreTool(creds, binDir, tool, args, entry, decryption)
+}
+
+// RestoreGlobals replays a globals artifact through psql while connected to
+// the postgres database. Errors do not stop the script, since roles and
+// tablespaces that already exist on the target are reported but harmless.
+func RestoreGlobals(creds *model.DatabaseCredentials, binDir string, entry model.CatalogEntry, decryption model.DecryptionConfig) error {
+	args := []string{
+		"--quiet",
+		fmt.Sprintf("--username=%s", creds.PgUser),
+		fmt.Sprintf("--host=%s", creds.PgHost),
+		fmt.Sprintf("--port=%s", creds.PgPort),
+		"--dbname=postgres",
+	}
+
+	log.Printf("Restoring globals %s into server %s:%s", entry.Path, creds.PgHost, creds.PgPort)
+	return runRestoreTool(creds, binDir, "psql", args, entry, decryption)
+}
+
+// runRestoreTool runs psql or pg_restore on the artifact. Compressed or
+// encrypted artifacts are decoded in process and streamed to stdin.
+func runRestoreTool(creds *model.DatabaseCredentials, binDir, tool string, args []string, entry model.CatalogEntry, decryption model.DecryptionConfig) error {
+	streamed := entry.Compression != model.CompressionNone || entry.Encrypted
+	switch {
+	case tool == "psql" && streamed:
+		// --single-transaction only covers -c and -f input, so stdin is read as a file
+		args = append(args, "--file=-")
+	case tool == "psql":
+		args = append(args, "--file", entry.Path)
+	case !streamed:
+		args = append(args, entry.Path)
+	}
+
+	command := exec.Command(platformFunc.ToolPath(binDir, tool), args...)
+	command.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", creds.PgPassword))
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if streamed {
+		input, err := OpenArtifact(entry, decryption)
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+		command.Stdin = input
+	}
+
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("error during restore: %v", err)
+	}
+
+	return nil
+}
+
+// OpenArtifact opens a file artifact and undoes its encryption and compression
+func OpenArtifact(entry model.CatalogEntry, decryption model.DecryptionConfig) (io.ReadCloser, error) {
+	if entry.Format == model.FormatDirectory {
+		return nil, fmt.Errorf("directory dumps cannot be streamed")
+	}
+
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening artifact: %v", err)
+	}
+
+	var reader io.Reader = file
+	if entry.Encrypted {
+		reader, err = encryptFunc.NewReader(reader, decryption)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	decompressed, err := compressFunc.NewReader(reader, entry.Compression)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error starting decompression: %v", err)
+	}
+
+	return &artifactReader{ReadCloser: decompressed, file: file}, nil
+}
+
+// CreateDatabaseIfNotExists creates the target database through a connection
+// to another database on the same server
+func CreateDatabaseIfNotExists(db *sql.DB, name string) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking database %s: %v", name, err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err = db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(name)); err != nil {
+		return fmt.Errorf("error creating database %s: %v", name, err)
+	}
+
+	log.Printf("Database %s created", name)
+	return nil
+}
+
+// artifactReader closes the decoder and the underlying file together
+type artifactReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r *artifactReader) Close() error {
+	r.ReadCloser.Close()
+	return r.file.Close()
+}
@@ -0,0 +1,141 @@
+package retentionFunc
+
+import (
+	"backup/catalogFunc"
+	"backup/manifestFunc"
+	"backup/model"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Prune applies the retention policy to every backup series below the output
+// root. A series is the set of backups of one kind sharing a name apart from
+// the run's time and ID.
+// With dryRun set, the decisions are only logged.
+func Prune(storage model.StorageConfig, policy model.RetentionPolicy, dryRun bool) error {
+	if policy == (model.RetentionPolicy{}) {
+		log.Println("No retention policy configured, nothing to prune")
+		return nil
+	}
+
+	entries, err := catalogFunc.List(storage)
+	if err != nil {
+		return err
+	}
+
+	series := map[string][]model.CatalogEntry{}
+	for _, entry := range entries {
+		key := entry.Series + "|" + entry.Kind
+		series[key] = append(series[key], entry)
+	}
+
+	var removed, kept int
+	for _, group := range series {
+		for _, decision := range Plan(group, policy) {
+			if decision.Keep {
+				kept++
+				if dryRun {
+					log.Printf("Keep   %s (%v)", decision.Entry.ID, decision.Reasons)
+				}
+				continue
+			}
+
+			removed++
+			if dryRun {
+				log.Printf("Remove %s", decision.Entry.ID)
+				continue
+			}
+			if err = os.RemoveAll(decision.Entry.Path); err != nil {
+				return fmt.Errorf("error removing %s: %v", decision.Entry.Path, err)
+			}
+			log.Printf("Removed %s", decision.Entry.Path)
+		}
+	}
+
+	if dryRun {
+		log.Printf("Dry run: %d backups would be kept, %d removed", kept, removed)
+		return nil
+	}
+
+	log.Printf("Pruning done: %d backups kept, %d removed", kept, removed)
+	return removeEmptyManifests(storage.OutputRoot)
+}
+
+// removeEmptyManifests deletes run manifests whose artifacts are all gone
+func removeEmptyManifests(root string) error {
+	manifests, err := manifestFunc.LoadAll(root)
+	if err != nil {
+		return err
+	}
+
+	for manifestPath, manifest := range manifests {
+		remaining := false
+		for _, artifact := range manifest.Artifacts {
+			if _, err = os.Stat(manifestFunc.ArtifactPath(manifestPath, artifact)); err == nil {
+				remaining = true
+				break
+			}
+		}
+		if remaining {
+			continue
+		}
+		if err = os.Remove(manifestPath); err != nil {
+			return fmt.Errorf("error removing manifest %s: %v", manifestPath, err)
+		}
+		log.Printf("Removed manifest of run %s", manifest.RunID)
+	}
+	return nil
+}
+
+// Plan decides which backups of one series to keep, newest first
+func Plan(entries []model.CatalogEntry, policy model.RetentionPolicy) []model.RetentionDecision {
+	sorted := append([]model.CatalogEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.After(sorted[j].Timestamp)
+	})
+
+	decisions := make([]model.RetentionDecision, len(sorted))
+	for i, entry := range sorted {
+		decisions[i].Entry = entry
+	}
+
+	keepBuckets(decisions, policy.KeepLast, "last", func(t time.Time, i int) string {
+		return fmt.Sprint(i)
+	})
+	keepBuckets(decisions, policy.KeepDaily, "daily", func(t time.Time, _ int) string {
+		return t.Format("2006-01-02")
+	})
+	keepBuckets(decisions, policy.KeepWeekly, "weekly", func(t time.Time, _ int) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", year, week)
+	})
+	keepBuckets(decisions, policy.KeepMonthly, "monthly", func(t time.Time, _ int) string {
+		return t.Format("2006-01")
+	})
+
+	return decisions
+}
+
+// keepBuckets keeps the newest backup of each of the newest count buckets
+func keepBuckets(decisions []model.RetentionDecision, count int, reason string, bucket func(time.Time, int) string) {
+	if count <= 0 {
+		return
+	}
+
+	seen := map[string]bool{}
+	for i := range decisions {
+		key := bucket(decisions[i].Entry.Timestamp, i)
+		if seen[key] {
+			continue
+		}
+		if len(seen) == count {
+			return
+		}
+		seen[key] = true
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, reason)
+	}
+}
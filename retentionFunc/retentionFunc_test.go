@@ -0,0 +1,110 @@
+package retentionFunc
+
+import (
+	"backup/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// Newest first: two backups on Sunday 10 March, one on Saturday in the
+	// same ISO week, then older weeks and months
+	backups := []model.CatalogEntry{
+		{ID: "a", Timestamp: at("2024-03-10 18:00")},
+		{ID: "b", Timestamp: at("2024-03-10 06:00")},
+		{ID: "c", Timestamp: at("2024-03-09 12:00")},
+		{ID: "d", Timestamp: at("2024-03-03 12:00")},
+		{ID: "e", Timestamp: at("2024-02-28 12:00")},
+		{ID: "f", Timestamp: at("2024-02-15 12:00")},
+		{ID: "g", Timestamp: at("2024-01-20 12:00")},
+	}
+
+	tests := []struct {
+		name   string
+		policy model.RetentionPolicy
+		// kept maps the kept backups to the rules keeping them
+		kept map[string][]string
+	}{
+		{
+			name:   "no policy",
+			policy: model.RetentionPolicy{},
+			kept:   map[string][]string{},
+		},
+		{
+			name:   "keep last",
+			policy: model.RetentionPolicy{KeepLast: 3},
+			kept:   map[string][]string{"a": {"last"}, "b": {"last"}, "c": {"last"}},
+		},
+		{
+			name:   "daily keeps the newest backup of each day",
+			policy: model.RetentionPolicy{KeepDaily: 3},
+			kept:   map[string][]string{"a": {"daily"}, "c": {"daily"}, "d": {"daily"}},
+		},
+		{
+			name:   "weekly uses ISO weeks",
+			policy: model.RetentionPolicy{KeepWeekly: 2},
+			kept:   map[string][]string{"a": {"weekly"}, "d": {"weekly"}},
+		},
+		{
+			name:   "monthly",
+			policy: model.RetentionPolicy{KeepMonthly: 2},
+			kept:   map[string][]string{"a": {"monthly"}, "e": {"monthly"}},
+		},
+		{
+			name:   "overlapping rules",
+			policy: model.RetentionPolicy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 2},
+			kept: map[string][]string{
+				"a": {"last", "daily", "weekly", "monthly"},
+				"c": {"daily"},
+				"d": {"weekly"},
+				"e": {"monthly"},
+			},
+		},
+		{
+			name:   "more buckets than backups",
+			policy: model.RetentionPolicy{KeepLast: 2, KeepMonthly: 12},
+			kept: map[string][]string{
+				"a": {"last", "monthly"},
+				"b": {"last"},
+				"e": {"monthly"},
+				"g": {"monthly"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Plan sorts itself, so hand it the backups oldest first
+			shuffled := make([]model.CatalogEntry, len(backups))
+			for i, entry := range backups {
+				shuffled[len(backups)-1-i] = entry
+			}
+
+			decisions := Plan(shuffled, test.policy)
+			if len(decisions) != len(backups) {
+				t.Fatalf("got %d decisions, want %d", len(decisions), len(backups))
+			}
+			for i, decision := range decisions {
+				if decision.Entry.ID != backups[i].ID {
+					t.Errorf("decision %d is for %s, want %s", i, decision.Entry.ID, backups[i].ID)
+				}
+				reasons, keep := test.kept[decision.Entry.ID]
+				if decision.Keep != keep {
+					t.Errorf("%s: keep = %v, want %v", decision.Entry.ID, decision.Keep, keep)
+				}
+				if keep && !reflect.DeepEqual(decision.Reasons, reasons) {
+					t.Errorf("%s: reasons = %v, want %v", decision.Entry.ID, decision.Reasons, reasons)
+				}
+			}
+		})
+	}
+}
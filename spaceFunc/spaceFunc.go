@@ -0,0 +1,59 @@
+package spaceFunc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Check compares the space a backup is expected to take with the free space
+// of the filesystem holding root. It fails when the backup would not fit and
+// warns when it would use more than warnPercent of the free space.
+func Check(root string, required int64, warnPercent int) error {
+	free, err := FreeSpace(existingParent(root))
+	if err != nil {
+		return fmt.Errorf("error reading free space of %s: %v", root, err)
+	}
+
+	log.Printf("Estimated backup size %s, %s free in %s", FormatBytes(required), FormatBytes(int64(free)), root)
+
+	if uint64(required) > free {
+		return fmt.Errorf("not enough disk space in %s: the backup needs about %s but only %s is free",
+			root, FormatBytes(required), FormatBytes(int64(free)))
+	}
+	if warnPercent > 0 && uint64(required)*100 > free*uint64(warnPercent) {
+		log.Printf("Warning: the backup will use more than %d%% of the free space in %s", warnPercent, root)
+	}
+	return nil
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. 1.5 GiB
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
+
+// existingParent returns path or its closest existing parent, since the
+// output root is only created by the first dump
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package spaceFunc
+
+import "golang.org/x/sys/windows"
+
+// FreeSpace returns the bytes available to the current user on the volume
+// holding path
+func FreeSpace(path string) (uint64, error) {
+	pathPointer, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available, total, free uint64
+	if err = windows.GetDiskFreeSpaceEx(pathPointer, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
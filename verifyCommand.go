@@ -0,0 +1,145 @@
+package main
+
+import (
+	"backup/catalogFunc"
+	"backup/config/backupConfig"
+	"backup/config/dbconfig"
+	"backup/config/locateTools"
+	"backup/model"
+	"backup/verifyFunc"
+	"backup/versionFunc"
+	"flag"
+	"log"
+	"strings"
+)
+
+// runVerify checks the integrity of the given backups, or of every backup
+// when none is given, and optionally test restores them
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	testRestore := flags.Bool("test-restore", false, "restore each backup into a throwaway database and compare row counts")
+	identityFile := flags.String("identity", "", "age identity file for encrypted backups")
+	withGlobals := flags.Bool("with-globals", false, "restore the run's globals onto the server before a test restore, resetting role passwords and attributes")
+	scanStorage := backupConfig.StorageFlags(flags)
+	pgBin := backupConfig.PgBinFlag(flags)
+	_ = flags.Parse(args)
+
+	storage, err := scanStorage()
+	if err != nil {
+		log.Fatalf("Error reading backup configuration: %v", err)
+	}
+
+	var entries []model.CatalogEntry
+	if flags.NArg() == 0 {
+		listed, err := catalogFunc.List(storage)
+		if err != nil {
+			log.Fatalf("Error listing backups: %v", err)
+		}
+		entries = listed
+	}
+	for _, reference := range flags.Args() {
+		entry, err := catalogFunc.Find(storage, reference)
+		if err != nil {
+			log.Fatalf("Error finding backup: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	decryption := backupConfig.ScanDecryptionConfig(*identityFile)
+
+	// A test restore needs the server, otherwise the installed tools are enough
+	var creds *model.DatabaseCredentials
+	var tools locateTools.Installation
+	if *testRestore {
+		var err error
+		creds, err = dbconfig.ScanCredsInformation()
+		if err != nil {
+			log.Fatalf("Error scanning credentials: %v", err)
+		}
+		db, err := dbconfig.CheckDatabaseConnection(creds)
+		if err != nil {
+			log.Fatalf("Database connection failed: %v", err)
+		}
+		tools, _, err = clientTools(db, *pgBin)
+		db.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// The newest pg_restore reads archives of every older version
+		var err error
+		if *pgBin != "" {
+			tools, err = locateTools.FromDir(*pgBin, versionFunc.Version{})
+		} else {
+			tools, err = locateTools.Newest()
+		}
+		if err != nil {
+			log.Fatalf("PostgreSQL client tools not found: %v", err)
+		}
+	}
+	pgRestorePath := tools.Tool("pg_restore")
+
+	failed := 0
+	for _, entry := range entries {
+		result := verifyFunc.VerifyArtifact(entry, pgRestorePath, decryption)
+
+		if *testRestore && len(result.Problems) == 0 && testRestorable(entry) {
+			if err := runTestRestore(storage, creds, tools.BinDir, entry, *withGlobals, decryption); err != nil {
+				result.Problems = append(result.Problems, err.Error())
+			} else {
+				result.Passed = append(result.Passed, "test restore")
+			}
+		}
+
+		if len(result.Problems) > 0 {
+			failed++
+			log.Printf("FAIL %s: %s", entry.ID, strings.Join(result.Problems, "; "))
+			continue
+		}
+		log.Printf("OK   %s (%s)", entry.ID, strings.Join(result.Passed, ", "))
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d of %d backups failed verification", failed, len(entries))
+	}
+	log.Printf("All %d backups verified", len(entries))
+}
+
+// testRestorable reports whether an artifact can be test restored into an
+// empty database. Data-only dumps need the schema first, and split dumps are
+// test restored once, through their pre-data section. Globals have no
+// database of their own and are restored with the dumps of their run.
+func testRestorable(entry model.CatalogEntry) bool {
+	switch entry.Kind {
+	case model.KindDump, model.KindSchema, model.KindPreData:
+		return true
+	default:
+		return false
+	}
+}
+
+// runTestRestore restores the artifact, or all sections of a split dump, into a
+// throwaway database, after the globals of its run when withGlobals is set
+func runTestRestore(storage model.StorageConfig, creds *model.DatabaseCredentials, binDir string, entry model.CatalogEntry, withGlobals bool, decryption model.DecryptionConfig) error {
+	artifacts := []model.CatalogEntry{entry}
+	if catalogFunc.IsSection(entry) {
+		sections, err := catalogFunc.Sections(storage, entry)
+		if err != nil {
+			return err
+		}
+		artifacts = sections
+	}
+
+	var globals *model.CatalogEntry
+	if withGlobals {
+		found, ok, err := catalogFunc.FindGlobals(storage, entry)
+		if err != nil {
+			return err
+		}
+		if ok {
+			globals = &found
+		}
+	}
+
+	return verifyFunc.TestRestore(creds, binDir, artifacts, globals, decryption)
+}
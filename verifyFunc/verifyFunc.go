@@ -0,0 +1,232 @@
+package verifyFunc
+
+import (
+	"backup/backupFunc"
+	"backup/config/dbconfig"
+	"backup/manifestFunc"
+	"backup/model"
+	"backup/restoreFunc"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/lib/pq"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// plainTrailer is written by pg_dump at the end of every complete plain dump
+const plainTrailer = "-- PostgreSQL database dump complete"
+
+// globalsTrailer is written by pg_dumpall at the end of a complete globals dump
+const globalsTrailer = "-- PostgreSQL database cluster dump complete"
+
+// tailSize is how much of the end of a plain dump is searched for the trailer
+const tailSize = 4096
+
+// VerifyArtifact checks the checksum recorded in the run manifest and that
+// the dump is complete: plain dumps must end with pg_dump's or pg_dumpall's
+// trailer and archives must be readable by pg_restore --list
+func VerifyArtifact(entry model.CatalogEntry, pgRestorePath string, decryption model.DecryptionConfig) model.VerifyResult {
+	result := model.VerifyResult{Entry: entry}
+
+	if err := checkChecksum(entry); err != nil {
+		result.Problems = append(result.Problems, err.Error())
+	} else if entry.ManifestPath != "" {
+		result.Passed = append(result.Passed, "checksum")
+	}
+
+	var err error
+	check := "pg_restore --list"
+	if entry.Format == model.FormatPlain {
+		check = "trailer"
+		err = checkPlainTrailer(entry, decryption)
+	} else {
+		err = checkArchiveList(entry, pgRestorePath, decryption)
+	}
+	if err != nil {
+		result.Problems = append(result.Problems, err.Error())
+	} else {
+		result.Passed = append(result.Passed, check)
+	}
+
+	return result
+}
+
+// TestRestore restores the artifacts, in order, into a throwaway database next
+// to the one in creds and compares its row counts with the counts recorded at
+// dump time. When globals is given, it is restored first.
+func TestRestore(creds *model.DatabaseCredentials, binDir string, entries []model.CatalogEntry, globals *model.CatalogEntry, decryption model.DecryptionConfig) error {
+	if globals != nil {
+		if err := restoreFunc.RestoreGlobals(creds, binDir, *globals, decryption); err != nil {
+			return fmt.Errorf("restoring globals failed: %v", err)
+		}
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("error generating database name: %v", err)
+	}
+
+	maintenance := *creds
+	maintenance.PgDatabase = "postgres"
+	maintenanceDB, err := dbconfig.CheckDatabaseConnection(&maintenance)
+	if err != nil {
+		return err
+	}
+	defer maintenanceDB.Close()
+
+	target := *creds
+	target.PgDatabase = "verify_" + hex.EncodeToString(suffix)
+	if err = restoreFunc.CreateDatabaseIfNotExists(maintenanceDB, target.PgDatabase); err != nil {
+		return err
+	}
+	defer maintenanceDB.Exec("DROP DATABASE IF EXISTS " + pq.QuoteIdentifier(target.PgDatabase))
+
+	var expected map[string]int64
+	options := model.RestoreOptions{SingleTransaction: true}
+	for _, entry := range entries {
+		if err = restoreFunc.RestoreBackup(&target, binDir, entry, options, decryption); err != nil {
+			return fmt.Errorf("test restore failed: %v", err)
+		}
+
+		counts, err := recordedRowCounts(entry)
+		if err != nil {
+			return err
+		}
+		if counts != nil {
+			expected = counts
+		}
+	}
+	if expected == nil {
+		return nil
+	}
+
+	db, err := dbconfig.CheckDatabaseConnection(&target)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	actual, err := backupFunc.CountRows(db, entries[0].Schema)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+	for table, count := range expected {
+		if actual[table] != count {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %d rows at dump time, %d restored", table, count, actual[table]))
+		}
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return fmt.Errorf("row counts differ: %v", mismatches)
+	}
+	return nil
+}
+
+func checkChecksum(entry model.CatalogEntry) error {
+	artifact, err := manifestArtifact(entry)
+	if err != nil || artifact == nil {
+		return err
+	}
+
+	size, checksum, err := manifestFunc.HashPath(entry.Path)
+	if err != nil {
+		return fmt.Errorf("error hashing artifact: %v", err)
+	}
+	if size != artifact.Size || checksum != artifact.SHA256 {
+		return fmt.Errorf("checksum mismatch: manifest has %s (%d bytes), artifact is %s (%d bytes)",
+			artifact.SHA256, artifact.Size, checksum, size)
+	}
+	return nil
+}
+
+func checkPlainTrailer(entry model.CatalogEntry, decryption model.DecryptionConfig) error {
+	input, err := restoreFunc.OpenArtifact(entry, decryption)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	tail := &tailWriter{size: tailSize}
+	if _, err = io.Copy(tail, input); err != nil {
+		return fmt.Errorf("error reading dump: %v", err)
+	}
+
+	trailer := plainTrailer
+	if entry.Kind == model.KindGlobals {
+		trailer = globalsTrailer
+	}
+	if !bytes.Contains(tail.data, []byte(trailer)) {
+		return fmt.Errorf("dump is truncated, completion trailer not found")
+	}
+	return nil
+}
+
+func checkArchiveList(entry model.CatalogEntry, pgRestorePath string, decryption model.DecryptionConfig) error {
+	command := exec.Command(pgRestorePath, "--list")
+
+	if entry.Compression != model.CompressionNone || entry.Encrypted {
+		input, err := restoreFunc.OpenArtifact(entry, decryption)
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+		command.Stdin = input
+	} else {
+		command.Args = append(command.Args, entry.Path)
+	}
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("pg_restore --list failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+func recordedRowCounts(entry model.CatalogEntry) (map[string]int64, error) {
+	artifact, err := manifestArtifact(entry)
+	if err != nil || artifact == nil {
+		return nil, err
+	}
+	return artifact.RowCounts, nil
+}
+
+// manifestArtifact returns the manifest record of an artifact, or nil when
+// the artifact is not part of a manifest
+func manifestArtifact(entry model.CatalogEntry) (*model.ManifestArtifact, error) {
+	if entry.ManifestPath == "" {
+		return nil, nil
+	}
+
+	manifest, err := manifestFunc.Load(entry.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, artifact := range manifest.Artifacts {
+		if filepath.Clean(manifestFunc.ArtifactPath(entry.ManifestPath, artifact)) == filepath.Clean(entry.Path) {
+			return &artifact, nil
+		}
+	}
+	return nil, nil
+}
+
+// tailWriter keeps only the last size bytes written to it
+type tailWriter struct {
+	size int
+	data []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.data = append(w.data, p...)
+	if len(w.data) > w.size {
+		w.data = append(w.data[:0], w.data[len(w.data)-w.size:]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,138 @@
+package versionFunc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Stages of a version, pre-releases order before the release they lead to
+const (
+	StageDevel = "devel"
+	StageBeta  = "beta"
+	StageRC    = "rc"
+)
+
+var stageOrder = map[string]int{StageDevel: 0, StageBeta: 1, StageRC: 2, "": 3}
+
+// Version is a PostgreSQL version. Num follows server_version_num, so 9.6.24
+// is 90624 and 16.4 is 160004. Pre-releases share the Num of their release,
+// 17beta2 is 170000 with Stage beta and StageNum 2.
+type Version struct {
+	Num      int
+	Stage    string
+	StageNum int
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?(devel|beta\d*|rc\d*)?`)
+
+// Parse reads a version as written by server_version or --version, such as
+// 16.4, 9.6.24, 17beta2 or "16.4 (Ubuntu 16.4-1.pgdg22.04+1)". Anything
+// after the version is ignored.
+func Parse(version string) (Version, error) {
+	matches := versionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return Version{}, fmt.Errorf("invalid PostgreSQL version %q", version)
+	}
+
+	parts := make([]int, 3)
+	for i, part := range matches[1:4] {
+		if part != "" {
+			parts[i], _ = strconv.Atoi(part)
+		}
+	}
+
+	var parsed Version
+	switch {
+	case parts[0] >= 10 && matches[3] != "":
+		return Version{}, fmt.Errorf("invalid PostgreSQL version %q, versions since 10 have two parts", version)
+	case parts[0] >= 10:
+		parsed.Num = parts[0]*10000 + parts[1]
+	default:
+		parsed.Num = parts[0]*10000 + parts[1]*100 + parts[2]
+	}
+
+	if stage := matches[4]; stage != "" {
+		for _, name := range []string{StageDevel, StageBeta, StageRC} {
+			if strings.HasPrefix(stage, name) {
+				parsed.Stage = name
+				parsed.StageNum, _ = strconv.Atoi(strings.TrimPrefix(stage, name))
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// FromNum returns the version of a server_version_num value
+func FromNum(num string) (Version, error) {
+	parsed, err := strconv.Atoi(strings.TrimSpace(num))
+	if err != nil || parsed < 10000 {
+		return Version{}, fmt.Errorf("invalid server_version_num %q", num)
+	}
+	return Version{Num: parsed}, nil
+}
+
+// Major returns the major version number in the Num scale, 906 for 9.6.24
+// and 1600 for 16.4, so majors of both eras compare correctly
+func (v Version) Major() int {
+	if v.Num >= 100000 {
+		return v.Num / 10000 * 100
+	}
+	return v.Num / 100
+}
+
+// MajorString returns the major version as written, 9.6 or 16
+func (v Version) MajorString() string {
+	if v.Num >= 100000 {
+		return strconv.Itoa(v.Num / 10000)
+	}
+	return fmt.Sprintf("%d.%d", v.Num/10000, v.Num/100%100)
+}
+
+// String returns the version as PostgreSQL writes it
+func (v Version) String() string {
+	var release string
+	switch {
+	case v.Stage != "":
+		release = v.MajorString() + v.Stage
+		if v.StageNum > 0 {
+			release += strconv.Itoa(v.StageNum)
+		}
+	case v.Num >= 100000:
+		release = fmt.Sprintf("%s.%d", v.MajorString(), v.Num%10000)
+	default:
+		release = fmt.Sprintf("%s.%d", v.MajorString(), v.Num%100)
+	}
+	return release
+}
+
+// Compare returns -1, 0 or 1 when v is older than, the same as or newer
+// than other
+func (v Version) Compare(other Version) int {
+	switch {
+	case v.Num != other.Num:
+		return sign(v.Num - other.Num)
+	case v.Stage != other.Stage:
+		return sign(stageOrder[v.Stage] - stageOrder[other.Stage])
+	default:
+		return sign(v.StageNum - other.StageNum)
+	}
+}
+
+// CanDump reports whether client tools of version v can work with a server
+// of version server, which needs at least the server's major version
+func (v Version) CanDump(server Version) bool {
+	return v.Major() >= server.Major()
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
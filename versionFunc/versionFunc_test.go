@@ -0,0 +1,142 @@
+package versionFunc
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   Version
+		major  string
+		output string
+	}{
+		{"16.4", Version{Num: 160004}, "16", "16.4"},
+		{"16.4 (Ubuntu 16.4-1.pgdg22.04+1)", Version{Num: 160004}, "16", "16.4"},
+		{"10.23", Version{Num: 100023}, "10", "10.23"},
+		{"9.6.24", Version{Num: 90624}, "9.6", "9.6.24"},
+		{"9.5.3", Version{Num: 90503}, "9.5", "9.5.3"},
+		{"8.4.22", Version{Num: 80422}, "8.4", "8.4.22"},
+		{"17beta2", Version{Num: 170000, Stage: StageBeta, StageNum: 2}, "17", "17beta2"},
+		{"17rc1", Version{Num: 170000, Stage: StageRC, StageNum: 1}, "17", "17rc1"},
+		{"18devel", Version{Num: 180000, Stage: StageDevel}, "18", "18devel"},
+		{"9.6beta1", Version{Num: 90600, Stage: StageBeta, StageNum: 1}, "9.6", "9.6beta1"},
+		{"16", Version{Num: 160000}, "16", "16.0"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.input, err)
+			}
+			if got != test.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.input, got, test.want)
+			}
+			if major := got.MajorString(); major != test.major {
+				t.Errorf("Parse(%q).MajorString() = %q, want %q", test.input, major, test.major)
+			}
+			if output := got.String(); output != test.output {
+				t.Errorf("Parse(%q).String() = %q, want %q", test.input, output, test.output)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{"", "PostgreSQL", "v16", "16.4.1"} {
+		if got, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", input, got)
+		}
+	}
+}
+
+func TestFromNum(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"160004", "16.4", false},
+		{"100023", "10.23", false},
+		{"90624", "9.6.24", false},
+		{"90503", "9.5.3", false},
+		{" 170000\n", "17.0", false},
+		{"", "", true},
+		{"16.4", "", true},
+		{"906", "", true},
+	}
+	for _, test := range tests {
+		got, err := FromNum(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("FromNum(%q) = %v, want an error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FromNum(%q) failed: %v", test.input, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("FromNum(%q) = %v, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"16.4", "16.4", 0},
+		{"16.4", "16.10", -1},
+		{"16.4", "17.0", -1},
+		{"10.1", "9.6.24", 1},
+		{"9.4.26", "9.5.0", -1},
+		{"9.5.10", "9.5.9", 1},
+		{"17devel", "17beta1", -1},
+		{"17beta1", "17beta2", -1},
+		{"17beta2", "17rc1", -1},
+		{"17rc1", "17.0", -1},
+		{"17rc1", "16.4", 1},
+		{"17.0", "17.0 (Debian 17.0-1)", 0},
+	}
+	for _, test := range tests {
+		a, err := Parse(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != test.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := b.Compare(a); got != -test.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestCanDump(t *testing.T) {
+	tests := []struct {
+		tools, server string
+		want          bool
+	}{
+		{"16.4", "16.1", true},
+		{"16.1", "16.4", true},
+		{"17.2", "16.4", true},
+		{"15.8", "16.4", false},
+		{"17beta2", "17.0", true},
+		{"9.6.24", "9.5.3", true},
+		{"9.5.25", "9.6.1", false},
+		{"10.1", "9.6.24", true},
+		{"9.6.24", "10.1", false},
+	}
+	for _, test := range tests {
+		tools, _ := Parse(test.tools)
+		server, _ := Parse(test.server)
+		if got := tools.CanDump(server); got != test.want {
+			t.Errorf("%s.CanDump(%s) = %v, want %v", test.tools, test.server, got, test.want)
+		}
+	}
+}